@@ -3,13 +3,21 @@ package chorm
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
+	"time"
 )
 
+// windowStartColumn - псевдоним начала временного окна TumblingWindow и
+// SlidingWindow
+const windowStartColumn = "window_start"
+
 // Aggregate представляет агрегатную функцию
 type Aggregate struct {
 	query *Query
 	funcs []string
+	// window - выражение начала временного окна с псевдонимом window_start
+	window string
 }
 
 // NewAggregate создает новый агрегат
@@ -60,6 +68,18 @@ func (a *Aggregate) CountDistinct(field string) *Aggregate {
 	return a
 }
 
+// CountDistinctMulti добавляет функцию COUNT DISTINCT по сочетанию полей.
+// Псевдоним составляется из имен полей: count_distinct_user_id_status
+func (a *Aggregate) CountDistinctMulti(fields ...string) *Aggregate {
+	alias := make([]string, len(fields))
+	for i, field := range fields {
+		alias[i] = strings.Trim(field, "`")
+	}
+	a.funcs = append(a.funcs, fmt.Sprintf("COUNT(DISTINCT %s) as count_distinct_%s",
+		strings.Join(fields, ", "), strings.Join(alias, "_")))
+	return a
+}
+
 // Uniq добавляет функцию uniq (ClickHouse специфичная)
 func (a *Aggregate) Uniq(field string) *Aggregate {
 	a.funcs = append(a.funcs, fmt.Sprintf("uniq(%s) as uniq_%s", field, field))
@@ -192,6 +212,68 @@ func (a *Aggregate) HarmonicMean(field string) *Aggregate {
 	return a
 }
 
+// TumblingWindow группирует строки по неперекрывающимся окнам длиной
+// windowSize: начало окна toStartOfInterval(timestampCol, INTERVAL N second)
+// выбирается как window_start и добавляется в GROUP BY. Повторный вызов
+// заменяет окно:
+//
+//	db.NewQuery().Table("events").
+//		NewAggregate().
+//		TumblingWindow(time.Minute, "created").
+//		Count("*").
+//		All(ctx, &rows)
+func (a *Aggregate) TumblingWindow(windowSize time.Duration, timestampCol string) *Aggregate {
+	size, err := windowSeconds("window size", windowSize)
+	if err != nil {
+		a.query.err = err
+		return a
+	}
+	a.setWindow(fmt.Sprintf("toStartOfInterval(%s, INTERVAL %d second)", timestampCol, size))
+	return a
+}
+
+// SlidingWindow группирует строки по перекрывающимся окнам длиной windowSize,
+// начала которых сдвинуты на stepSize. Каждая строка попадает в
+// windowSize/stepSize окон через arrayJoin, начало окна выбирается как
+// window_start и добавляется в GROUP BY. windowSize должен быть кратен
+// stepSize
+func (a *Aggregate) SlidingWindow(windowSize, stepSize time.Duration, timestampCol string) *Aggregate {
+	size, err := windowSeconds("window size", windowSize)
+	if err != nil {
+		a.query.err = err
+		return a
+	}
+	step, err := windowSeconds("window step", stepSize)
+	if err != nil {
+		a.query.err = err
+		return a
+	}
+	if size%step != 0 {
+		a.query.err = fmt.Errorf("window size %s is not a multiple of step %s", windowSize, stepSize)
+		return a
+	}
+	a.setWindow(fmt.Sprintf(
+		"arrayJoin(arrayMap(i -> toStartOfInterval(%s, INTERVAL %d second) - toIntervalSecond(i * %d), range(%d)))",
+		timestampCol, step, step, size/step))
+	return a
+}
+
+// setWindow задает выражение начала окна и добавляет window_start в GROUP BY
+func (a *Aggregate) setWindow(expr string) {
+	if a.window == "" {
+		a.query.groupBy = append(a.query.groupBy, windowStartColumn)
+	}
+	a.window = expr + " AS " + windowStartColumn
+}
+
+// windowSeconds возвращает длительность окна в целых секундах
+func windowSeconds(name string, d time.Duration) (int64, error) {
+	if d < time.Second || d%time.Second != 0 {
+		return 0, fmt.Errorf("%s must be a positive whole number of seconds, got %s", name, d)
+	}
+	return int64(d / time.Second), nil
+}
+
 // Get выполняет агрегатный запрос и возвращает результат
 func (a *Aggregate) Get(ctx context.Context, result interface{}) error {
 	if len(a.funcs) == 0 {
@@ -199,7 +281,7 @@ func (a *Aggregate) Get(ctx context.Context, result interface{}) error {
 	}
 
 	// Устанавливаем SELECT с агрегатными функциями
-	a.query.selects = a.funcs
+	a.applySelects()
 
 	// Выполняем запрос
 	return a.query.Get(ctx, result)
@@ -212,12 +294,34 @@ func (a *Aggregate) All(ctx context.Context, result interface{}) error {
 	}
 
 	// Устанавливаем SELECT с агрегатными функциями
-	a.query.selects = a.funcs
+	a.applySelects()
 
 	// Выполняем запрос
 	return a.query.All(ctx, result)
 }
 
+// applySelects устанавливает SELECT из колонок GROUP BY и агрегатных функций,
+// чтобы каждая строка результата содержала и ключи группировки, и значения.
+// Аргументы выражений GroupByExpr повторяются для их копий в SELECT
+func (a *Aggregate) applySelects() {
+	selects := make([]string, 0, len(a.query.groupBy)+len(a.funcs))
+	var selectArgs []interface{}
+	groupByArgs := a.query.groupByArgs
+	for _, field := range a.query.groupBy {
+		if n := countPlaceholders(field); n > 0 && n <= len(groupByArgs) {
+			selectArgs = append(selectArgs, groupByArgs[:n]...)
+			groupByArgs = groupByArgs[n:]
+		}
+		// Окно выбирается выражением, а группируется по псевдониму
+		if a.window != "" && field == windowStartColumn {
+			field = a.window
+		}
+		selects = append(selects, field)
+	}
+	selects = append(selects, a.funcs...)
+	a.query.selects, a.query.selectArgs = selects, selectArgs
+}
+
 // Window представляет оконную функцию
 type Window struct {
 	query    *Query
@@ -352,3 +456,108 @@ func (w *Window) AddToQuery() *Query {
 
 	return w.query
 }
+
+// Pivot представляет сводную таблицу: строки группируются по rowKey,
+// а значения colKey разворачиваются в колонки
+type Pivot struct {
+	query     *Query
+	rowKey    string
+	colKey    string
+	valueExpr string
+	columns   []string
+}
+
+// Pivot создает сводную таблицу по запросу
+func (q *Query) Pivot(rowKey, colKey, valueExpr string) *Pivot {
+	return &Pivot{
+		query:     q,
+		rowKey:    rowKey,
+		colKey:    colKey,
+		valueExpr: valueExpr,
+	}
+}
+
+// Columns задает фиксированный набор колонок сводной таблицы.
+// В этом случае вместо sumMap используется форма sumIf для каждой колонки
+func (p *Pivot) Columns(values ...string) *Pivot {
+	p.columns = values
+	return p
+}
+
+// build строит SQL и аргументы сводного запроса
+func (p *Pivot) build() (string, []interface{}) {
+	pq := *p.query
+	pq.groupBy = []string{p.rowKey}
+	if len(pq.orderBy) == 0 {
+		pq.orderBy = []string{p.rowKey + " ASC"}
+	}
+
+	var args []interface{}
+	selects := []string{fmt.Sprintf("%s AS pivot_row", p.rowKey)}
+
+	if len(p.columns) > 0 {
+		for _, column := range p.columns {
+			selects = append(selects, fmt.Sprintf("sumIf(%s, toString(%s) = ?) AS `%s`", p.valueExpr, p.colKey, column))
+			args = append(args, column)
+		}
+	} else {
+		selects = append(selects,
+			fmt.Sprintf("tupleElement(sumMap([toString(%s)], [%s]) AS pivot_map, 1) AS pivot_cols", p.colKey, p.valueExpr),
+			"tupleElement(pivot_map, 2) AS pivot_values")
+	}
+	pq.selects = selects
+
+	// Аргументы WITH предшествуют аргументам SELECT, GROUP BY заменен ключом строк
+	args = append(append(pq.withArgs(), args...), p.query.args...)
+	return pq.buildSQL(), append(args, p.query.havingArgs...)
+}
+
+// BuildSQL возвращает SQL сводного запроса
+func (p *Pivot) BuildSQL() string {
+	sql, _ := p.build()
+	return sql
+}
+
+// Get выполняет сводный запрос и заполняет результат вида row -> column -> value
+func (p *Pivot) Get(ctx context.Context, result *map[string]map[string]interface{}) error {
+	sql, args := p.build()
+
+	p.query.db.debugf("Pivot SQL: %s", sql)
+	p.query.db.debugf("Pivot Args: %v", args)
+
+	var rows []map[string]interface{}
+	if err := p.query.db.Query(ctx, &rows, sql, args...); err != nil {
+		return err
+	}
+
+	*result = pivotRows(rows, p.columns)
+	return nil
+}
+
+// pivotRows преобразует строки сводного запроса во вложенные map
+func pivotRows(rows []map[string]interface{}, columns []string) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{}, len(rows))
+
+	for _, row := range rows {
+		key := fmt.Sprintf("%v", row["pivot_row"])
+		values := make(map[string]interface{})
+
+		if len(columns) > 0 {
+			for _, column := range columns {
+				values[column] = row[column]
+			}
+		} else {
+			cols := reflect.ValueOf(row["pivot_cols"])
+			vals := reflect.ValueOf(row["pivot_values"])
+			if cols.Kind() == reflect.Slice && vals.Kind() == reflect.Slice {
+				for i := 0; i < cols.Len() && i < vals.Len(); i++ {
+					values[fmt.Sprintf("%v", cols.Index(i).Interface())] = vals.Index(i).Interface()
+				}
+			}
+		}
+
+		result[key] = values
+	}
+
+	return result
+}
@@ -0,0 +1,65 @@
+package chorm
+
+import "context"
+
+// AuditProvider возвращает идентификатор пользователя, выполняющего запись,
+// например из значения контекста HTTP-запроса. Пустая строка означает, что
+// пользователь неизвестен и поля аудита не заполняются
+type AuditProvider func(ctx context.Context) string
+
+// WithAuditProvider возвращает копию DB, которая заполняет поля аудита
+// пользователем из fn: при вставке - ch_created_by (если оно пусто) и
+// ch_updated_by, в Save и BulkUpdate (для модели из Model) - ch_updated_by:
+//
+//	db = db.WithAuditProvider(func(ctx context.Context) string {
+//		user, _ := ctx.Value(userKey{}).(string)
+//		return user
+//	})
+func (db *DB) WithAuditProvider(fn AuditProvider) *DB {
+	clone := *db
+	clone.auditProvider = fn
+	return &clone
+}
+
+// auditUser возвращает пользователя из AuditProvider или пустую строку
+func (db *DB) auditUser(ctx context.Context) string {
+	if db.auditProvider == nil {
+		return ""
+	}
+	return db.auditProvider(ctx)
+}
+
+// applyAudit присваивает user полям ch_updated_by, а при вставке (insert) -
+// и пустым полям ch_created_by. Модель, переданная по значению, копируется:
+// возвращается модель, из которой читаются значения
+func applyAudit(info *TableInfo, model interface{}, user string, insert bool) interface{} {
+	if user == "" || !hasAuditFields(info) {
+		return model
+	}
+
+	elem, model := settableModel(model)
+	if !elem.IsValid() {
+		return model
+	}
+
+	for _, field := range info.Fields {
+		value := elem.FieldByName(field.FieldName)
+		switch {
+		case field.UpdatedBy:
+			value.SetString(user)
+		case field.CreatedBy && insert && value.String() == "":
+			value.SetString(user)
+		}
+	}
+	return model
+}
+
+// hasAuditFields проверяет, есть ли у модели поля ch_created_by или ch_updated_by
+func hasAuditFields(info *TableInfo) bool {
+	for _, field := range info.Fields {
+		if field.CreatedBy || field.UpdatedBy {
+			return true
+		}
+	}
+	return false
+}
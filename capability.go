@@ -0,0 +1,70 @@
+package chorm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotSupported возвращается для операций, недоступных в текущей конфигурации
+var ErrNotSupported = errors.New("operation not supported")
+
+// Capability представляет возможность, зависящую от протокола подключения
+type Capability string
+
+const (
+	// CapabilityFormatStreaming - потоковая передача данных в формате FORMAT (CSV, JSONEachRow)
+	// без разбора строк на стороне Go. Доступна только через HTTP
+	CapabilityFormatStreaming Capability = "format streaming"
+	// CapabilityNativeBatch - вставка блоками нативного протокола. Через HTTP
+	// вставка выполняется обычным INSERT ... VALUES
+	CapabilityNativeBatch Capability = "native batch insert"
+	// CapabilityConnectionSession - сессии с настройками SET и временными
+	// таблицами. Через HTTP сессия задается параметром session_id
+	CapabilityConnectionSession Capability = "connection session"
+)
+
+// capabilities описывает, какими протоколами поддерживается возможность
+var capabilities = map[Capability][]Protocol{
+	CapabilityFormatStreaming:   {ProtocolHTTP},
+	CapabilityNativeBatch:       {ProtocolNative},
+	CapabilityConnectionSession: {ProtocolNative, ProtocolHTTP},
+}
+
+// CapabilityError сообщает, что возможность недоступна для выбранного протокола
+type CapabilityError struct {
+	Capability Capability
+	Protocol   Protocol
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("%s is not supported over %s protocol", e.Capability, e.Protocol)
+}
+
+// Unwrap позволяет проверять ошибку через errors.Is(err, ErrNotSupported)
+func (e *CapabilityError) Unwrap() error {
+	return ErrNotSupported
+}
+
+// Protocol возвращает протокол подключения
+func (db *DB) Protocol() Protocol {
+	if db.config.Protocol == "" {
+		return ProtocolNative
+	}
+	return db.config.Protocol
+}
+
+// Supports проверяет, доступна ли возможность для протокола подключения
+func (db *DB) Supports(capability Capability) bool {
+	return db.requireCapability(capability) == nil
+}
+
+// requireCapability возвращает CapabilityError, если возможность недоступна
+func (db *DB) requireCapability(capability Capability) error {
+	protocol := db.Protocol()
+	for _, p := range capabilities[capability] {
+		if p == protocol {
+			return nil
+		}
+	}
+	return &CapabilityError{Capability: capability, Protocol: protocol}
+}
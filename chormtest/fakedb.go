@@ -0,0 +1,240 @@
+// Package chormtest содержит FakeDB - подмену ClickHouse для модульных
+// тестов кода, использующего chorm
+package chormtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AlanForester/chorm"
+)
+
+// Call описывает запрос, выполненный через FakeDB
+type Call struct {
+	SQL  string
+	Args []interface{}
+}
+
+// response - заготовленный ответ на запросы, содержащие подстроку match
+type response struct {
+	match string
+	rows  []map[string]interface{}
+	err   error
+}
+
+// FakeDB - chorm.DB поверх драйвера в памяти: запросы не отправляются на
+// сервер, а записываются вместе с аргументами. SELECT возвращает строки,
+// заданные через SetRows, которые проходят через обычное сканирование chorm
+// в структуры и map. Реализует chorm.Conn:
+//
+//	db := chormtest.New()
+//	db.SetRows("FROM users", []map[string]interface{}{{"id": uint32(1), "name": "John"}})
+//	svc := NewUserService(db)
+type FakeDB struct {
+	*chorm.DB
+
+	mu        sync.Mutex
+	calls     []Call
+	responses []response
+}
+
+// New создает FakeDB. Запросы без заготовленного ответа возвращают пустой результат
+func New() *FakeDB {
+	f := &FakeDB{}
+	f.DB = chorm.NewDB(sql.OpenDB(&connector{fake: f}), chorm.Config{Host: "fake", Database: "fake"})
+	return f
+}
+
+// SetRows задает строки, возвращаемые запросами, SQL которых содержит match
+// (пустая строка подходит для всех запросов). Используется первый подходящий
+// ответ в порядке регистрации
+func (f *FakeDB) SetRows(match string, rows []map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, response{match: match, rows: rows})
+}
+
+// SetError задает ошибку для запросов, SQL которых содержит match
+func (f *FakeDB) SetError(match string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, response{match: match, err: err})
+}
+
+// Calls возвращает выполненные запросы в порядке выполнения
+func (f *FakeDB) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// LastCall возвращает последний выполненный запрос (нулевой Call, если их не было)
+func (f *FakeDB) LastCall() Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.calls) == 0 {
+		return Call{}
+	}
+	return f.calls[len(f.calls)-1]
+}
+
+// Reset удаляет записанные запросы и заготовленные ответы
+func (f *FakeDB) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+	f.responses = nil
+}
+
+// record записывает запрос и возвращает подходящий ответ
+func (f *FakeDB) record(query string, args []driver.NamedValue) response {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	call := Call{SQL: query, Args: make([]interface{}, len(args))}
+	for i, arg := range args {
+		call.Args[i] = arg.Value
+	}
+	f.calls = append(f.calls, call)
+
+	for _, r := range f.responses {
+		if strings.Contains(query, r.match) {
+			return r
+		}
+	}
+	return response{}
+}
+
+// connector, conn, stmt, tx и rows реализуют драйвер database/sql поверх FakeDB
+type connector struct {
+	fake *FakeDB
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{fake: c.fake}, nil
+}
+
+func (c *connector) Driver() driver.Driver { return nil }
+
+type conn struct {
+	fake *FakeDB
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) { return tx{}, nil }
+
+// CheckNamedValue принимает аргументы любых типов, как драйвер ClickHouse
+// принимает срезы и map
+func (c *conn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r := c.fake.record(query, args)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r := c.fake.record(query, args)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return newRows(r.rows), nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+// namedValues преобразует позиционные аргументы в driver.NamedValue
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+// rows возвращает заготовленные строки. Колонки - объединение ключей всех
+// строк в алфавитном порядке; отсутствующие в строке ключи равны NULL
+type rows struct {
+	columns []string
+	types   []reflect.Type
+	data    []map[string]interface{}
+}
+
+func newRows(data []map[string]interface{}) *rows {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range data {
+		for column := range row {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+
+	// Тип колонки - тип ее значений; колонки с NULL сканируются в interface{}
+	anyType := reflect.TypeOf((*interface{})(nil)).Elem()
+	types := make([]reflect.Type, len(columns))
+	for i, column := range columns {
+		for _, row := range data {
+			value := row[column]
+			if value == nil || (types[i] != nil && types[i] != reflect.TypeOf(value)) {
+				types[i] = anyType
+				break
+			}
+			types[i] = reflect.TypeOf(value)
+		}
+	}
+
+	return &rows{columns: columns, types: types, data: data}
+}
+
+func (r *rows) Columns() []string { return r.columns }
+
+func (r *rows) ColumnTypeScanType(index int) reflect.Type { return r.types[index] }
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+	for i, column := range r.columns {
+		dest[i] = r.data[0][column]
+	}
+	r.data = r.data[1:]
+	return nil
+}
@@ -0,0 +1,87 @@
+package chormtest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AlanForester/chorm"
+)
+
+type testUser struct {
+	ID   uint32 `ch:"id" ch_type:"UInt32"`
+	Name string `ch:"name" ch_type:"String"`
+}
+
+func (u *testUser) TableName() string {
+	return "users"
+}
+
+// activeUsers - пример кода, зависящего от chorm.Conn
+func activeUsers(ctx context.Context, db chorm.Conn, minAge int) ([]testUser, error) {
+	var users []testUser
+	err := db.NewQuery().Table("users").Where("age >= ?", minAge).OrderBy("id").All(ctx, &users)
+	return users, err
+}
+
+// TestFakeDB тестирует запись запросов и заготовленные ответы
+func TestFakeDB(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+	defer db.Close()
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	db.SetRows("FROM users", []map[string]interface{}{
+		{"id": uint32(1), "name": "John", "created": created},
+		{"id": uint32(2), "name": "Jane"},
+	})
+	db.SetError("FROM broken", errors.New("code: 60, message: Table doesn't exist"))
+
+	users, err := activeUsers(ctx, db, 18)
+	if err != nil {
+		t.Fatalf("activeUsers failed: %v", err)
+	}
+	expected := []testUser{{ID: 1, Name: "John"}, {ID: 2, Name: "Jane"}}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("Expected %v, got %v", expected, users)
+	}
+
+	last := db.LastCall()
+	if last.SQL != "SELECT * FROM users WHERE age >= ? ORDER BY id ASC" || !reflect.DeepEqual(last.Args, []interface{}{18}) {
+		t.Errorf("Unexpected call: %+v", last)
+	}
+
+	var rows []map[string]interface{}
+	if err := db.Query(ctx, &rows, "SELECT * FROM users"); err != nil || len(rows) != 2 ||
+		rows[0]["created"] != created || rows[1]["created"] != nil || rows[1]["name"] != "Jane" {
+		t.Errorf("Expected map rows, got %v (%v)", rows, err)
+	}
+
+	if err := db.Insert(ctx, &testUser{ID: 3, Name: "Bob"}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	insert := db.LastCall()
+	if insert.SQL != "INSERT INTO `users` (`id`, `name`) VALUES (?, ?)" ||
+		!reflect.DeepEqual(insert.Args, []interface{}{uint32(3), "Bob"}) {
+		t.Errorf("Unexpected insert call: %+v", insert)
+	}
+
+	if _, err := db.Exec(ctx, "SELECT * FROM broken"); err == nil {
+		t.Error("Expected canned error")
+	}
+
+	var count uint64
+	if err := db.QueryRow(ctx, &count, "SELECT count() FROM events"); err == nil {
+		t.Error("Expected no rows for a query without canned rows")
+	}
+
+	if n := len(db.Calls()); n != 5 {
+		t.Errorf("Expected 5 calls, got %d", n)
+	}
+	db.Reset()
+	if len(db.Calls()) != 0 || db.LastCall().SQL != "" {
+		t.Error("Expected Reset to clear calls")
+	}
+}
@@ -0,0 +1,84 @@
+// Package testcontainer запускает ClickHouse в Docker для интеграционных
+// тестов кода, использующего chorm. Пакет вынесен в отдельный модуль, чтобы
+// основной модуль chorm не зависел от testcontainers и Docker клиента
+package testcontainer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/AlanForester/chorm"
+	"github.com/AlanForester/chorm/internal/chcontainer"
+
+	// Драйвер database/sql "clickhouse"
+	_ "github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// Контейнер запускается один раз на процесс тестов и удаляется сборщиком
+// testcontainers после завершения процесса
+var (
+	containerOnce sync.Once
+	container     *chcontainer.Container
+	containerErr  error
+)
+
+// StartClickHouse запускает ClickHouse в Docker (один контейнер на процесс
+// тестов), создает для теста отдельную базу данных и возвращает DB,
+// подключенную к ней. После теста база удаляется, а DB закрывается.
+// Требует доступный Docker:
+//
+//	db := testcontainer.StartClickHouse(t)
+func StartClickHouse(t testing.TB) *chorm.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	containerOnce.Do(func() {
+		container, containerErr = chcontainer.Start(ctx, "")
+	})
+	if containerErr != nil {
+		t.Fatalf("testcontainer: %v", containerErr)
+	}
+
+	admin, err := chorm.Connect(ctx, containerConfig("default"))
+	if err != nil {
+		t.Fatalf("testcontainer: failed to connect to clickhouse: %v", err)
+	}
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	database := "chormtest_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec(ctx, "CREATE DATABASE `"+database+"`"); err != nil {
+		admin.Close()
+		t.Fatalf("testcontainer: failed to create database: %v", err)
+	}
+
+	db, err := chorm.Connect(ctx, containerConfig(database))
+	if err != nil {
+		admin.Close()
+		t.Fatalf("testcontainer: failed to connect to %s: %v", database, err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		if _, err := admin.Exec(ctx, "DROP DATABASE IF EXISTS `"+database+"`"); err != nil {
+			t.Errorf("testcontainer: failed to drop database %s: %v", database, err)
+		}
+		admin.Close()
+	})
+
+	return db
+}
+
+// containerConfig возвращает конфигурацию подключения к базе database в контейнере
+func containerConfig(database string) chorm.Config {
+	return chorm.Config{
+		Host:     container.Host,
+		Port:     container.NativePort,
+		Database: database,
+		Username: chcontainer.Username,
+		Password: chcontainer.Password,
+	}
+}
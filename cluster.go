@@ -2,9 +2,12 @@ package chorm
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -121,7 +124,7 @@ func (c *Cluster) HealthCheck(ctx context.Context) {
 		}
 
 		// Проверяем подключение
-		if err := db.conn.PingContext(ctx); err != nil {
+		if err := db.Ping(ctx); err != nil {
 			node.Healthy = false
 		} else {
 			node.Healthy = true
@@ -132,10 +135,66 @@ func (c *Cluster) HealthCheck(ctx context.Context) {
 	}
 }
 
-// ClusterDB представляет подключение к кластеру
+// ClusterDB представляет подключение к кластеру. Query, Exec и
+// InsertIntoDistributed выполняются через постоянные пулы соединений узлов,
+// которые открываются при первом обращении и закрываются в Close
 type ClusterDB struct {
 	cluster *Cluster
 	config  Config
+	hooks   []Hook
+	log     *DB // Журнал по конфигурации кластера, без соединения
+
+	mu      sync.Mutex
+	pools   map[string]*nodePool
+	connect func(ctx context.Context, config Config) (*sql.DB, error) // openDB, если не задана
+
+	healthStop chan struct{}
+	healthDone chan struct{}
+}
+
+// NodeStats представляет состояние пула соединений узла кластера
+type NodeStats struct {
+	Host            string
+	Port            int
+	OpenConnections int
+	InUse           int
+	Idle            int
+	LastPing        time.Time     // Время последней успешной проверки
+	LastPingLatency time.Duration // Длительность последней проверки
+	Errors          int64         // Число неудачных проверок и запросов
+	Healthy         bool
+}
+
+// nodePool представляет постоянный пул соединений узла кластера
+type nodePool struct {
+	db     *DB
+	errors atomic.Int64
+
+	mu          sync.Mutex
+	pingLatency time.Duration
+}
+
+// Before реализует Hook
+func (p *nodePool) Before(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+// After реализует Hook и учитывает неудачные запросы к узлу
+func (p *nodePool) After(ctx context.Context, event *QueryEvent) {
+	if event.Err != nil {
+		p.errors.Add(1)
+	}
+}
+
+// Use добавляет хук, который применяется к подключениям ко всем узлам кластера
+func (cdb *ClusterDB) Use(hook Hook) {
+	cdb.mu.Lock()
+	defer cdb.mu.Unlock()
+
+	cdb.hooks = append(cdb.hooks, hook)
+	for _, p := range cdb.pools {
+		p.db.Use(hook)
+	}
 }
 
 // NewClusterDB создает новое подключение к кластеру
@@ -143,6 +202,7 @@ func NewClusterDB(cluster *Cluster, config Config) *ClusterDB {
 	return &ClusterDB{
 		cluster: cluster,
 		config:  config,
+		log:     &DB{config: config},
 	}
 }
 
@@ -156,52 +216,236 @@ func ConnectToCluster(cluster *Cluster, config Config) (*ClusterDB, error) {
 		return nil, fmt.Errorf("no healthy nodes in cluster")
 	}
 
-	return &ClusterDB{
-		cluster: cluster,
-		config:  config,
-	}, nil
+	return NewClusterDB(cluster, config), nil
 }
 
-// GetConnection получает подключение к случайному здоровому узлу
+// GetConnection открывает отдельное подключение к случайному здоровому узлу,
+// которое закрывает вызывающий
 func (cdb *ClusterDB) GetConnection(ctx context.Context) (*DB, error) {
 	node := cdb.cluster.GetNodeByWeight()
 	if node == nil {
 		return nil, fmt.Errorf("no available nodes in cluster")
 	}
 
-	config := Config{
-		Host:     node.Host,
-		Port:     node.Port,
-		Database: node.Database,
-		Username: node.Username,
-		Password: node.Password,
+	db, err := Connect(ctx, cdb.nodeConfig(node))
+	if err != nil {
+		return nil, err
 	}
 
-	return Connect(ctx, config)
+	cdb.mu.Lock()
+	defer cdb.mu.Unlock()
+	for _, hook := range cdb.hooks {
+		db.Use(hook)
+	}
+	return db, nil
+}
+
+// nodeConfig возвращает конфигурацию подключения к узлу: адрес узла и
+// остальные параметры (TLS, протокол, журнал, таймауты, настройки) из
+// конфигурации кластера. База данных и учетные данные узла имеют приоритет
+// над заданными в конфигурации кластера
+func (cdb *ClusterDB) nodeConfig(node *ClusterNode) Config {
+	config := cdb.config
+	config.Host = node.Host
+	config.Port = node.Port
+	if node.Database != "" {
+		config.Database = node.Database
+	}
+	if node.Username != "" {
+		config.Username = node.Username
+		config.Password = node.Password
+	}
+	return config
+}
+
+// nodeKey возвращает ключ узла в виде host:port
+func nodeKey(node *ClusterNode) string {
+	return fmt.Sprintf("%s:%d", node.Host, node.Port)
+}
+
+// pool возвращает постоянный пул соединений узла, создавая его при первом
+// обращении. Подключение к узлу выполняется при первом запросе
+func (cdb *ClusterDB) pool(node *ClusterNode) *nodePool {
+	cdb.mu.Lock()
+	defer cdb.mu.Unlock()
+
+	key := nodeKey(node)
+	if p, ok := cdb.pools[key]; ok {
+		return p
+	}
+
+	db := ConnectLazy(context.Background(), cdb.nodeConfig(node))
+	db.state.connect = cdb.connect
+	p := &nodePool{db: db}
+	db.Use(p)
+	for _, hook := range cdb.hooks {
+		db.Use(hook)
+	}
+
+	if cdb.pools == nil {
+		cdb.pools = make(map[string]*nodePool)
+	}
+	cdb.pools[key] = p
+	return p
+}
+
+// nodeDB возвращает пул соединений случайного здорового узла
+func (cdb *ClusterDB) nodeDB() (*DB, error) {
+	node := cdb.cluster.GetNodeByWeight()
+	if node == nil {
+		return nil, fmt.Errorf("no available nodes in cluster")
+	}
+	return cdb.pool(node).db, nil
 }
 
 // Query выполняет запрос на случайном узле кластера
 func (cdb *ClusterDB) Query(ctx context.Context, result interface{}, query string, args ...interface{}) error {
-	db, err := cdb.GetConnection(ctx)
+	db, err := cdb.nodeDB()
 	if err != nil {
 		return err
 	}
-	defer db.Close()
-
 	return db.Query(ctx, result, query, args...)
 }
 
 // Exec выполняет команду на случайном узле кластера
 func (cdb *ClusterDB) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
-	db, err := cdb.GetConnection(ctx)
+	db, err := cdb.nodeDB()
 	if err != nil {
 		return Result{}, err
 	}
-	defer db.Close()
-
 	return db.Exec(ctx, query, args...)
 }
 
+// HealthCheck проверяет узлы кластера через их пулы соединений и обновляет
+// здоровье узлов и статистику Stats: длительность проверки и число ошибок
+func (cdb *ClusterDB) HealthCheck(ctx context.Context) {
+	cdb.cluster.mu.RLock()
+	nodes := make([]*ClusterNode, len(cdb.cluster.Nodes))
+	copy(nodes, cdb.cluster.Nodes)
+	cdb.cluster.mu.RUnlock()
+
+	for _, node := range nodes {
+		p := cdb.pool(node)
+
+		start := time.Now()
+		err := p.db.Ping(ctx)
+		latency := time.Since(start)
+
+		p.mu.Lock()
+		p.pingLatency = latency
+		p.mu.Unlock()
+		if err != nil {
+			p.errors.Add(1)
+		}
+
+		cdb.cluster.mu.Lock()
+		node.Healthy = err == nil
+		if err == nil {
+			node.LastPing = start
+		}
+		cdb.cluster.mu.Unlock()
+	}
+}
+
+// StartHealthCheck запускает фоновую проверку узлов HealthCheck с интервалом
+// interval. Проверка останавливается в Close, повторный вызов игнорируется
+func (cdb *ClusterDB) StartHealthCheck(interval time.Duration) {
+	cdb.mu.Lock()
+	defer cdb.mu.Unlock()
+	if cdb.healthStop != nil || interval <= 0 {
+		return
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	cdb.healthStop, cdb.healthDone = stop, done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				cdb.HealthCheck(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// Name возвращает имя кластера
+func (cdb *ClusterDB) Name() string {
+	return cdb.cluster.Name
+}
+
+// Stats возвращает статистику пулов соединений узлов кластера по ключу
+// host:port. Для узлов без открытого пула возвращаются нулевые значения пула
+func (cdb *ClusterDB) Stats() map[string]NodeStats {
+	cdb.cluster.mu.RLock()
+	nodes := make([]ClusterNode, len(cdb.cluster.Nodes))
+	for i, node := range cdb.cluster.Nodes {
+		nodes[i] = *node
+	}
+	cdb.cluster.mu.RUnlock()
+
+	cdb.mu.Lock()
+	defer cdb.mu.Unlock()
+
+	stats := make(map[string]NodeStats, len(nodes))
+	for i := range nodes {
+		node := &nodes[i]
+		key := nodeKey(node)
+		s := NodeStats{
+			Host:     node.Host,
+			Port:     node.Port,
+			LastPing: node.LastPing,
+			Healthy:  node.Healthy,
+		}
+		if p, ok := cdb.pools[key]; ok {
+			pool := p.db.Stats()
+			s.OpenConnections = pool.OpenConnections
+			s.InUse = pool.InUse
+			s.Idle = pool.Idle
+			s.Errors = p.errors.Load()
+
+			p.mu.Lock()
+			s.LastPingLatency = p.pingLatency
+			p.mu.Unlock()
+		}
+		stats[key] = s
+	}
+	return stats
+}
+
+// Close останавливает фоновую проверку узлов и закрывает пулы соединений узлов
+func (cdb *ClusterDB) Close() error {
+	cdb.mu.Lock()
+	stop, done := cdb.healthStop, cdb.healthDone
+	cdb.healthStop, cdb.healthDone = nil, nil
+	cdb.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	cdb.mu.Lock()
+	defer cdb.mu.Unlock()
+
+	var firstErr error
+	for key, p := range cdb.pools {
+		if err := p.db.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close node %s: %w", key, err)
+		}
+	}
+	cdb.pools = nil
+	return firstErr
+}
+
 // CreateDistributedTable создает распределенную таблицу
 func (cdb *ClusterDB) CreateDistributedTable(ctx context.Context, tableName, clusterName, localTableName string, shardingKey string) error {
 	sql := fmt.Sprintf(`
@@ -215,15 +459,98 @@ func (cdb *ClusterDB) CreateDistributedTable(ctx context.Context, tableName, clu
 
 // InsertIntoDistributed вставляет данные в распределенную таблицу
 func (cdb *ClusterDB) InsertIntoDistributed(ctx context.Context, tableName string, data interface{}) error {
-	db, err := cdb.GetConnection(ctx)
+	db, err := cdb.nodeDB()
 	if err != nil {
 		return err
 	}
-	defer db.Close()
-
 	return db.Insert(ctx, data)
 }
 
+// ScatterGatherError возвращается ScatterGather, если запрос завершился
+// ошибкой более чем на половине узлов. Errors содержит ошибки по узлам
+// (host:port), Nodes - число опрошенных узлов
+type ScatterGatherError struct {
+	Errors map[string]error
+	Nodes  int
+}
+
+func (e *ScatterGatherError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	failures := make([]string, len(keys))
+	for i, key := range keys {
+		failures[i] = fmt.Sprintf("%s: %v", key, e.Errors[key])
+	}
+	return fmt.Sprintf("query failed on %d of %d nodes: %s", len(e.Errors), e.Nodes, strings.Join(failures, "; "))
+}
+
+// ScatterGather параллельно выполняет запрос query на всех здоровых узлах
+// кластера и передает частичные результаты успешных узлов (в порядке узлов
+// кластера) в merge. Ошибки отдельных узлов журналируются; если ошибкой
+// завершилось более половины узлов, возвращается ScatterGatherError:
+//
+//	rows, err := cdb.ScatterGather(ctx,
+//		db.NewQuery().Table("events_local").Select("user_id", "count() AS n").GroupBy("user_id"),
+//		func(parts [][]map[string]interface{}) ([]map[string]interface{}, error) {
+//			var merged []map[string]interface{}
+//			for _, part := range parts {
+//				merged = append(merged, part...)
+//			}
+//			return merged, nil
+//		})
+func (cdb *ClusterDB) ScatterGather(ctx context.Context, query *Query, merge func([][]map[string]interface{}) ([]map[string]interface{}, error)) ([]map[string]interface{}, error) {
+	if err := query.validate(); err != nil {
+		return nil, err
+	}
+	nodes := cdb.cluster.GetHealthyNodes()
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no available nodes in cluster")
+	}
+
+	sql, args := query.ToSQL()
+	results := make([][]map[string]interface{}, len(nodes))
+	errs := make([]error, len(nodes))
+
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node *ClusterNode) {
+			defer wg.Done()
+			errs[i] = cdb.pool(node).db.Query(ctx, &results[i], sql, args...)
+		}(i, node)
+	}
+	wg.Wait()
+
+	failed := make(map[string]error)
+	partials := make([][]map[string]interface{}, 0, len(nodes))
+	for i, node := range nodes {
+		if errs[i] != nil {
+			failed[nodeKey(node)] = errs[i]
+			continue
+		}
+		partials = append(partials, results[i])
+	}
+
+	if len(failed) > 0 {
+		sgErr := &ScatterGatherError{Errors: failed, Nodes: len(nodes)}
+		if len(failed)*2 > len(nodes) {
+			return nil, sgErr
+		}
+		// Журнал кластера задается его конфигурацией
+		cdb.log.warnf("Scatter-gather continues with partial results: %v", sgErr)
+	}
+
+	merged, err := merge(partials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge results: %w", err)
+	}
+	return merged, nil
+}
+
 // ReplicatedTable представляет реплицированную таблицу
 type ReplicatedTable struct {
 	Name          string
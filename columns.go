@@ -0,0 +1,57 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ColumnMeta описывает колонку результата запроса
+type ColumnMeta struct {
+	Name         string
+	DatabaseType string       // Тип ClickHouse, например Nullable(String)
+	Nullable     bool         // Колонка допускает NULL
+	ScanType     reflect.Type // Go тип, в который драйвер сканирует значения (nil, если неизвестен)
+}
+
+// QueryColumns выполняет запрос и возвращает метаданные колонок результата
+// без чтения строк, например для динамического отображения таблицы. Если
+// драйвер не сообщает, допускает ли колонка NULL, это определяется по типу
+// Nullable(...)
+func (db *DB) QueryColumns(ctx context.Context, query string, args ...interface{}) ([]ColumnMeta, error) {
+	db.debugf("QueryColumns SQL: %s", query)
+	db.debugf("QueryColumns Args: %v", args)
+
+	ctx, event := db.beforeQuery(ctx, query, args)
+	rows, err := db.queryConn(ctx, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", db.finishQuery(ctx, event, 0, err))
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		err = fmt.Errorf("failed to get column types: %w", err)
+		db.finishQuery(ctx, event, 0, err)
+		return nil, err
+	}
+
+	columns := make([]ColumnMeta, len(columnTypes))
+	for i, columnType := range columnTypes {
+		column := ColumnMeta{
+			Name:         columnType.Name(),
+			DatabaseType: columnType.DatabaseTypeName(),
+			ScanType:     columnType.ScanType(),
+		}
+		if nullable, ok := columnType.Nullable(); ok {
+			column.Nullable = nullable
+		} else {
+			column.Nullable = strings.HasPrefix(column.DatabaseType, "Nullable(")
+		}
+		columns[i] = column
+	}
+
+	db.finishQuery(ctx, event, 0, nil)
+	return columns, nil
+}
@@ -0,0 +1,499 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEnvPrefix префикс переменных окружения по умолчанию
+const DefaultEnvPrefix = "CHORM"
+
+// Значения пула соединений по умолчанию
+const (
+	defaultMaxOpenConns = 10
+	defaultMaxIdleConns = 5
+)
+
+// setDefaults заполняет незаданные параметры значениями по умолчанию. Это
+// единственное место, где задаются значения по умолчанию: Connect, ConnectLazy,
+// Validate, DSN и ConfigFromEnv вызывают его
+func (c *Config) setDefaults() {
+	if c.Protocol == "" {
+		c.Protocol = ProtocolNative
+	}
+	if c.Port == 0 {
+		c.Port = c.defaultPort()
+	}
+	if c.MaxOpenConns == 0 {
+		c.MaxOpenConns = defaultMaxOpenConns
+	}
+	if c.MaxIdleConns == 0 {
+		// Не превышаем явно заданный MaxOpenConns
+		c.MaxIdleConns = defaultMaxIdleConns
+		if c.MaxOpenConns > 0 && c.MaxOpenConns < c.MaxIdleConns {
+			c.MaxIdleConns = c.MaxOpenConns
+		}
+	}
+	if c.ConnMaxLifetime == 0 {
+		c.ConnMaxLifetime = time.Hour
+	}
+}
+
+// defaultPort возвращает порт по умолчанию: стандартный порт HTTP(S) для
+// ProtocolHTTP и 9000 для нативного протокола, в том числе с TLS (порт
+// нативного TLS 9440 задается явно)
+func (c *Config) defaultPort() int {
+	switch {
+	case c.Protocol == ProtocolHTTP && c.TLS:
+		return 8443
+	case c.Protocol == ProtocolHTTP:
+		return 8123
+	default:
+		return 9000
+	}
+}
+
+// dsn строит строку подключения для драйвера clickhouse-go
+func (c *Config) dsn() string {
+	scheme := "clickhouse"
+	if c.Protocol == ProtocolHTTP {
+		scheme = "http"
+		if c.TLS {
+			scheme = "https"
+		}
+	}
+
+	var params []string
+
+	if c.DialTimeout > 0 {
+		params = append(params, "dial_timeout="+c.DialTimeout.String())
+	}
+	if c.ReadTimeout > 0 {
+		params = append(params, "read_timeout="+c.ReadTimeout.String())
+	}
+	if c.WriteTimeout > 0 {
+		params = append(params, "write_timeout="+c.WriteTimeout.String())
+	}
+	// Явная настройка max_execution_time в Settings имеет приоритет
+	if _, ok := c.Settings["max_execution_time"]; c.MaxExecutionTime > 0 && !ok {
+		params = append(params, fmt.Sprintf("max_execution_time=%d", durationSeconds(c.MaxExecutionTime)))
+	}
+
+	if c.TLS && c.Protocol != ProtocolHTTP {
+		params = append(params, "secure=true")
+	}
+
+	if method := c.compressionMethod(); method != "" {
+		params = append(params, "compress="+string(method))
+		if c.CompressionLevel != 0 {
+			params = append(params, fmt.Sprintf("compress_level=%d", c.CompressionLevel))
+		}
+	}
+
+	keys := make([]string, 0, len(c.Settings))
+	for k := range c.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		params = append(params, fmt.Sprintf("%s=%s", url.QueryEscape(k), url.QueryEscape(dsnSettingValue(c.Settings[k]))))
+	}
+
+	dsn := fmt.Sprintf("%s://%s@%s:%d/%s", scheme, url.UserPassword(c.Username, c.Password), c.Host, c.Port, c.Database)
+	if len(params) > 0 {
+		dsn += "?" + strings.Join(params, "&")
+	}
+
+	return dsn
+}
+
+// DSN возвращает строку подключения, которую использовал бы Connect, например
+// для sql.Open с драйвером clickhouse-go. Соединение не открывается
+func (c Config) DSN() string {
+	c.setDefaults()
+	return c.dsn()
+}
+
+// RedactedDSN возвращает DSN с паролем, замененным на ***, для журналов
+func (c Config) RedactedDSN() string {
+	dsn := c.DSN()
+	if c.Password == "" {
+		return dsn
+	}
+	userinfo := url.UserPassword(c.Username, c.Password).String() + "@"
+	return strings.Replace(dsn, userinfo, url.User(c.Username).String()+":***@", 1)
+}
+
+// ConfigError описывает ошибку в одном поле конфигурации
+type ConfigError struct {
+	Field   string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ConfigErrors объединяет все ошибки конфигурации, найденные Validate
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate проверяет конфигурацию и возвращает все найденные ошибки как
+// ConfigErrors (nil, если ошибок нет). Незаданные поля проверяются со
+// значениями по умолчанию, которые подставит Connect
+func (c Config) Validate() error {
+	c.setDefaults()
+
+	var errs ConfigErrors
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, ConfigError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Host == "" {
+		add("Host", "must not be empty")
+	} else if strings.Contains(c.Host, "://") || strings.Contains(c.Host, "/") {
+		add("Host", "must be a host name without scheme or path, got %q", c.Host)
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		add("Port", "must be between 1 and 65535, got %d", c.Port)
+	}
+	if c.Database == "" {
+		add("Database", "must not be empty")
+	}
+
+	// Отрицательный MaxOpenConns означает пул без ограничения (как в database/sql)
+	if c.MaxOpenConns > 0 && c.MaxOpenConns < c.MaxIdleConns {
+		add("MaxIdleConns", "must not exceed MaxOpenConns (%d), got %d", c.MaxOpenConns, c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime < 0 {
+		add("ConnMaxLifetime", "must be positive, got %s", c.ConnMaxLifetime)
+	}
+	for _, timeout := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"DialTimeout", c.DialTimeout},
+		{"ReadTimeout", c.ReadTimeout},
+		{"WriteTimeout", c.WriteTimeout},
+		{"MaxExecutionTime", c.MaxExecutionTime},
+		{"SessionTimeout", c.SessionTimeout},
+		{"KeepAliveInterval", c.KeepAliveInterval},
+	} {
+		if timeout.value < 0 {
+			add(timeout.field, "must not be negative, got %s", timeout.value)
+		}
+	}
+
+	switch c.Protocol {
+	case ProtocolNative, ProtocolHTTP:
+	default:
+		add("Protocol", "must be %q or %q, got %q", ProtocolNative, ProtocolHTTP, c.Protocol)
+	}
+
+	switch c.Placeholder {
+	case "", PlaceholderQuestion, PlaceholderDollar, PlaceholderAtP:
+	default:
+		add("Placeholder", "unsupported placeholder style %q", c.Placeholder)
+	}
+
+	for _, file := range []struct{ field, path string }{
+		{"TLSCertFile", c.TLSCertFile},
+		{"TLSKeyFile", c.TLSKeyFile},
+		{"TLSCAFile", c.TLSCAFile},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if info, err := os.Stat(file.path); os.IsNotExist(err) {
+			add(file.field, "file %s does not exist", file.path)
+		} else if err != nil {
+			add(file.field, "%v", err)
+		} else if info.IsDir() {
+			add(file.field, "%s is a directory", file.path)
+		}
+	}
+	if (c.TLSCertFile != "" || c.TLSKeyFile != "" || c.TLSCAFile != "") && !c.TLS {
+		add("TLS", "must be enabled when TLS certificate files are set")
+	}
+	if c.TLSCertFile != "" && c.TLSKeyFile == "" {
+		add("TLSKeyFile", "must be set together with TLSCertFile")
+	}
+	if c.TLSKeyFile != "" && c.TLSCertFile == "" {
+		add("TLSCertFile", "must be set together with TLSKeyFile")
+	}
+
+	if err := c.validateCompression(); err != nil {
+		add("CompressionMethod", "%v", err)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// compressionMethod возвращает выбранный алгоритм сжатия: CompressionMethod,
+// либо LZ4 при включенном Compression, либо пустую строку
+func (c *Config) compressionMethod() CompressionMethod {
+	if c.CompressionMethod != "" {
+		return CompressionMethod(strings.ToLower(string(c.CompressionMethod)))
+	}
+	if c.Compression {
+		return CompressionLZ4
+	}
+	return ""
+}
+
+// validateCompression проверяет, что алгоритм и уровень сжатия поддерживаются протоколом
+func (c *Config) validateCompression() error {
+	method := c.compressionMethod()
+	if method == "" {
+		if c.CompressionLevel != 0 {
+			return fmt.Errorf("compression level %d is set but compression is disabled", c.CompressionLevel)
+		}
+		return nil
+	}
+
+	var minLevel, maxLevel int
+	switch method {
+	case CompressionLZ4:
+		// LZ4 не поддерживает уровни сжатия
+	case CompressionZSTD:
+		minLevel, maxLevel = 1, 22
+	case CompressionGZIP, CompressionDeflate:
+		minLevel, maxLevel = -2, 9
+	case CompressionBrotli:
+		minLevel, maxLevel = 0, 11
+	default:
+		return fmt.Errorf("unsupported compression method %q", method)
+	}
+
+	switch method {
+	case CompressionGZIP, CompressionDeflate, CompressionBrotli:
+		if c.Protocol != ProtocolHTTP {
+			return fmt.Errorf("compression method %q is only supported over %s protocol", method, ProtocolHTTP)
+		}
+	}
+
+	if c.CompressionLevel != 0 && (c.CompressionLevel < minLevel || c.CompressionLevel > maxLevel) {
+		if minLevel == maxLevel {
+			return fmt.Errorf("compression method %q does not support compression levels", method)
+		}
+		return fmt.Errorf("compression level %d is out of range [%d, %d] for %q",
+			c.CompressionLevel, minLevel, maxLevel, method)
+	}
+
+	return nil
+}
+
+// dsnSettingValue форматирует значение настройки для DSN (без кавычек)
+func dsnSettingValue(value interface{}) string {
+	if b, ok := value.(bool); ok {
+		return formatSettingValue(b)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// durationSeconds округляет длительность вверх до целых секунд
+func durationSeconds(d time.Duration) int64 {
+	seconds := int64(d / time.Second)
+	if d%time.Second != 0 {
+		seconds++
+	}
+	return seconds
+}
+
+// ConfigFromEnv читает конфигурацию из переменных окружения вида <prefix>_HOST.
+// Пустой prefix означает DefaultEnvPrefix. Поддерживаются переменные
+// HOST, PORT, DATABASE, USERNAME, PASSWORD, PROTOCOL, TLS, COMPRESSION,
+// COMPRESSION_METHOD, COMPRESSION_LEVEL, DEBUG, MAX_OPEN_CONNS, MAX_IDLE_CONNS, CONN_MAX_LIFETIME, DIAL_TIMEOUT, READ_TIMEOUT,
+// WRITE_TIMEOUT и MAX_EXECUTION_TIME (длительности в формате "30s", "5m").
+// Незаданные параметры получают те же значения по умолчанию, что и в Connect
+func ConfigFromEnv(prefix string) (Config, error) {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	env := envReader{prefix: strings.TrimSuffix(prefix, "_") + "_"}
+
+	config := Config{
+		Host:     env.String("HOST"),
+		Database: env.String("DATABASE"),
+		Username: env.String("USERNAME"),
+		Password: env.String("PASSWORD"),
+		Protocol: Protocol(strings.ToLower(env.String("PROTOCOL"))),
+
+		CompressionMethod: CompressionMethod(strings.ToLower(env.String("COMPRESSION_METHOD"))),
+	}
+
+	if config.Protocol != "" && config.Protocol != ProtocolNative && config.Protocol != ProtocolHTTP {
+		return Config{}, fmt.Errorf("invalid %sPROTOCOL: %q", env.prefix, config.Protocol)
+	}
+
+	var err error
+	if config.Port, err = env.Int("PORT"); err != nil {
+		return Config{}, err
+	}
+	if config.MaxOpenConns, err = env.Int("MAX_OPEN_CONNS"); err != nil {
+		return Config{}, err
+	}
+	if config.MaxIdleConns, err = env.Int("MAX_IDLE_CONNS"); err != nil {
+		return Config{}, err
+	}
+	if config.ConnMaxLifetime, err = env.Duration("CONN_MAX_LIFETIME"); err != nil {
+		return Config{}, err
+	}
+	if config.DialTimeout, err = env.Duration("DIAL_TIMEOUT"); err != nil {
+		return Config{}, err
+	}
+	if config.ReadTimeout, err = env.Duration("READ_TIMEOUT"); err != nil {
+		return Config{}, err
+	}
+	if config.WriteTimeout, err = env.Duration("WRITE_TIMEOUT"); err != nil {
+		return Config{}, err
+	}
+	if config.MaxExecutionTime, err = env.Duration("MAX_EXECUTION_TIME"); err != nil {
+		return Config{}, err
+	}
+	if config.TLS, err = env.Bool("TLS"); err != nil {
+		return Config{}, err
+	}
+	if config.Compression, err = env.Bool("COMPRESSION"); err != nil {
+		return Config{}, err
+	}
+	if config.CompressionLevel, err = env.Int("COMPRESSION_LEVEL"); err != nil {
+		return Config{}, err
+	}
+	if config.Debug, err = env.Bool("DEBUG"); err != nil {
+		return Config{}, err
+	}
+
+	config.setDefaults()
+	return config, nil
+}
+
+// ConnectEnv читает конфигурацию из окружения и подключается к ClickHouse
+func ConnectEnv(ctx context.Context, prefix string) (*DB, error) {
+	config, err := ConfigFromEnv(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return Connect(ctx, config)
+}
+
+// FromEnv возвращает копию конфигурации, в которой поля, заданные переменными
+// окружения CH_HOST, CH_PORT, CH_DATABASE, CH_USERNAME, CH_PASSWORD,
+// CH_MAX_OPEN_CONNS, CH_MAX_IDLE_CONNS, CH_DEBUG, CH_COMPRESSION и CH_TLS,
+// заменены их значениями. Остальные поля (в том числе заданные переменными с
+// некорректным значением) не меняются, значения по умолчанию не подставляются:
+//
+//	config := chorm.Config{Database: "analytics"}.FromEnv()
+func (c Config) FromEnv() Config {
+	env := envReader{prefix: "CH_"}
+
+	env.overlayString("HOST", &c.Host)
+	env.overlayInt("PORT", &c.Port)
+	env.overlayString("DATABASE", &c.Database)
+	env.overlayString("USERNAME", &c.Username)
+	env.overlayString("PASSWORD", &c.Password)
+	env.overlayInt("MAX_OPEN_CONNS", &c.MaxOpenConns)
+	env.overlayInt("MAX_IDLE_CONNS", &c.MaxIdleConns)
+	env.overlayBool("DEBUG", &c.Debug)
+	env.overlayBool("COMPRESSION", &c.Compression)
+	env.overlayBool("TLS", &c.TLS)
+
+	return c
+}
+
+// ConnectFromEnv подключается к ClickHouse с конфигурацией Config{}.FromEnv()
+func ConnectFromEnv(ctx context.Context) (*DB, error) {
+	return Connect(ctx, Config{}.FromEnv())
+}
+
+// envReader читает типизированные значения переменных окружения
+type envReader struct {
+	prefix string
+}
+
+// String возвращает строковое значение переменной
+func (e envReader) String(name string) string {
+	return os.Getenv(e.prefix + name)
+}
+
+// Int возвращает целочисленное значение переменной (0, если не задана)
+func (e envReader) Int(name string) (int, error) {
+	value := e.String(name)
+	if value == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s%s: %w", e.prefix, name, err)
+	}
+	return i, nil
+}
+
+// Bool возвращает булево значение переменной (false, если не задана)
+func (e envReader) Bool(name string) (bool, error) {
+	value := e.String(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s%s: %w", e.prefix, name, err)
+	}
+	return b, nil
+}
+
+// Duration возвращает длительность из переменной (0, если не задана)
+func (e envReader) Duration(name string) (time.Duration, error) {
+	value := e.String(name)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s%s: %w", e.prefix, name, err)
+	}
+	return d, nil
+}
+
+// overlayString записывает значение переменной в dst, если переменная задана
+func (e envReader) overlayString(name string, dst *string) {
+	if value := e.String(name); value != "" {
+		*dst = value
+	}
+}
+
+// overlayInt записывает значение переменной в dst, если переменная задана и корректна
+func (e envReader) overlayInt(name string, dst *int) {
+	if e.String(name) == "" {
+		return
+	}
+	if value, err := e.Int(name); err == nil {
+		*dst = value
+	}
+}
+
+// overlayBool записывает значение переменной в dst, если переменная задана и корректна
+func (e envReader) overlayBool(name string, dst *bool) {
+	if e.String(name) == "" {
+		return
+	}
+	if value, err := e.Bool(name); err == nil {
+		*dst = value
+	}
+}
@@ -0,0 +1,232 @@
+package chorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// connState хранит пул соединений DB, общий для ее копий (WithRowTransformer
+// и т.п.). Пул открывается при первом обращении и заменяется при переподключении
+type connState struct {
+	mu      sync.Mutex
+	conn    *sql.DB
+	connect func(ctx context.Context, config Config) (*sql.DB, error) // openDB, если не задана
+
+	// keepAliveStop останавливает горутину keep-alive, keepAliveDone
+	// закрывается после ее завершения
+	keepAliveStop chan struct{}
+	keepAliveDone chan struct{}
+}
+
+// openDB проверяет конфигурацию, открывает пул соединений и проверяет подключение
+func openDB(ctx context.Context, config Config) (*sql.DB, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	// Подключаемся к базе данных
+	conn, err := sql.Open("clickhouse", config.dsn())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ClickHouse: %w", redactError(err, config.Password))
+	}
+
+	// Настраиваем пул соединений
+	conn.SetMaxOpenConns(config.MaxOpenConns)
+	conn.SetMaxIdleConns(config.MaxIdleConns)
+	conn.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	// Проверяем подключение
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to ping ClickHouse: %w", redactError(classifyError(err), config.Password))
+	}
+
+	return conn, nil
+}
+
+// EnsureConnected подключается к серверу, если DB создана через ConnectLazy
+// и подключение еще не выполнено. Неудачная попытка повторяется при следующем вызове
+func (db *DB) EnsureConnected(ctx context.Context) error {
+	_, err := db.pool(ctx)
+	return err
+}
+
+// Ping проверяет соединение с сервером
+func (db *DB) Ping(ctx context.Context) error {
+	return db.withConn(ctx, true, func(conn *sql.DB) error {
+		return conn.PingContext(ctx)
+	})
+}
+
+// pool возвращает пул соединений, подключаясь при первом обращении.
+// Одновременные вызовы подключаются один раз
+func (db *DB) pool(ctx context.Context) (*sql.DB, error) {
+	if db.state == nil {
+		return db.conn, nil
+	}
+
+	db.state.mu.Lock()
+	defer db.state.mu.Unlock()
+
+	if db.state.conn == nil {
+		conn, err := db.open(ctx)
+		if err != nil {
+			return nil, err
+		}
+		db.state.conn = conn
+
+		if db.config.KeepAliveInterval > 0 && db.state.keepAliveStop == nil {
+			db.state.keepAliveStop = make(chan struct{})
+			db.state.keepAliveDone = make(chan struct{})
+			go db.keepAlive(db.config.KeepAliveInterval, db.state.keepAliveStop, db.state.keepAliveDone)
+		}
+	}
+	return db.state.conn, nil
+}
+
+// keepAlive каждые interval проверяет простаивающие соединения пула, пока
+// не закрыт stop
+func (db *DB) keepAlive(interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			db.pingIdle(ctx)
+			cancel()
+		}
+	}
+}
+
+// pingIdle пингует простаивающие соединения. Соединения, не ответившие на
+// ping, закрываются и не возвращаются в пул, поэтому первый запрос после
+// простоя не получает соединение, разорванное балансировщиком
+func (db *DB) pingIdle(ctx context.Context) {
+	db.state.mu.Lock()
+	pool := db.state.conn
+	db.state.mu.Unlock()
+	if pool == nil {
+		return
+	}
+
+	// Соединения удерживаются до конца проверки, чтобы каждое проверялось один раз
+	idle := pool.Stats().Idle
+	conns := make([]*sql.Conn, 0, idle)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	for i := 0; i < idle; i++ {
+		conn, err := pool.Conn(ctx)
+		if err != nil {
+			return
+		}
+		conns = append(conns, conn)
+
+		if err := conn.PingContext(ctx); err != nil {
+			db.debugf("Keep-alive ping failed: %v", err)
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}
+}
+
+// stopKeepAlive останавливает горутину keep-alive и ждет ее завершения
+func (db *DB) stopKeepAlive() {
+	db.state.mu.Lock()
+	stop, done := db.state.keepAliveStop, db.state.keepAliveDone
+	db.state.keepAliveStop, db.state.keepAliveDone = nil, nil
+	db.state.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// open открывает новый пул соединений и вызывает Config.OnConnect с DB,
+// работающей на этом пуле. Вызывается под db.state.mu
+func (db *DB) open(ctx context.Context) (*sql.DB, error) {
+	connect := db.state.connect
+	if connect == nil {
+		connect = openDB
+	}
+
+	conn, err := connect(ctx, db.config)
+	if err != nil {
+		return nil, err
+	}
+
+	if db.config.OnConnect != nil {
+		connected := *db
+		connected.state = nil
+		connected.conn = conn
+		if err := db.config.OnConnect(&connected); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to run OnConnect: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// reconnect заменяет пул соединений failed после ошибки соединения cause.
+// Если пул уже заменен одновременным запросом, возвращается текущий
+func (db *DB) reconnect(ctx context.Context, failed *sql.DB, cause error) (*sql.DB, error) {
+	db.state.mu.Lock()
+	defer db.state.mu.Unlock()
+
+	if db.state.conn != nil && db.state.conn != failed {
+		return db.state.conn, nil
+	}
+
+	if db.config.OnReconnect != nil {
+		db.config.OnReconnect(cause)
+	}
+
+	conn, err := db.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if failed != nil {
+		failed.Close()
+	}
+	db.state.conn = conn
+	return conn, nil
+}
+
+// withConn выполняет fn на пуле соединений. При ошибке соединения DB,
+// созданная через Connect или ConnectLazy, один раз переподключается. fn
+// повторяется только при retry: изменяющий запрос мог быть выполнен сервером
+// до разрыва соединения, поэтому его ошибка возвращается без повтора
+func (db *DB) withConn(ctx context.Context, retry bool, fn func(conn *sql.DB) error) error {
+	conn, err := db.pool(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(conn)
+	if err == nil || db.state == nil || ctx.Err() != nil || !isConnectionError(err) {
+		return err
+	}
+
+	conn, reconnectErr := db.reconnect(ctx, conn, err)
+	if reconnectErr != nil {
+		return fmt.Errorf("%w (reconnect failed: %v)", err, reconnectErr)
+	}
+	if !retry {
+		return err
+	}
+	return fn(conn)
+}
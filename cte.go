@@ -0,0 +1,69 @@
+package chorm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commonTableExpression представляет именованный подзапрос WITH
+type commonTableExpression struct {
+	name      string
+	sql       string
+	args      []interface{}
+	recursive bool
+}
+
+// WithRecursive добавляет рекурсивное табличное выражение
+// WITH RECURSIVE name AS (baseSQL UNION ALL recursiveSQL) и читает из него:
+// таблицей запроса становится name (последующий Table ее заменяет).
+// recursiveSQL ссылается на name, чтобы получить строки предыдущего шага.
+// Аргументы подставляются в порядке baseArgs, recursiveArgs, затем аргументы
+// самого запроса:
+//
+//	err := db.NewQuery().
+//		WithRecursive("tree",
+//			"SELECT id, parent_id, 0 AS depth FROM categories WHERE id = ?",
+//			"SELECT c.id, c.parent_id, t.depth + 1 FROM categories c JOIN tree t ON c.parent_id = t.id WHERE t.depth < ?",
+//			[]interface{}{rootID}, []interface{}{maxDepth}).
+//		OrderByAsc("depth").OrderByAsc("id").
+//		All(ctx, &nodes)
+func (q *Query) WithRecursive(name, baseSQL, recursiveSQL string, baseArgs, recursiveArgs []interface{}) *Query {
+	if name == "" || baseSQL == "" || recursiveSQL == "" {
+		q.err = fmt.Errorf("WithRecursive requires a name, a base query and a recursive query")
+		return q
+	}
+
+	args := make([]interface{}, 0, len(baseArgs)+len(recursiveArgs))
+	args = append(args, baseArgs...)
+	args = append(args, recursiveArgs...)
+
+	q.ctes = append(q.ctes, commonTableExpression{
+		name:      name,
+		sql:       baseSQL + " UNION ALL " + recursiveSQL,
+		args:      args,
+		recursive: true,
+	})
+	return q.Table(name)
+}
+
+// buildWith строит часть WITH с табличными выражениями запроса
+func (q *Query) buildWith() string {
+	keyword := "WITH"
+	parts := make([]string, len(q.ctes))
+	for i, cte := range q.ctes {
+		if cte.recursive {
+			keyword = "WITH RECURSIVE"
+		}
+		parts[i] = fmt.Sprintf("%s AS (%s)", cte.name, cte.sql)
+	}
+	return keyword + " " + strings.Join(parts, ", ")
+}
+
+// withArgs возвращает аргументы табличных выражений в порядке их плейсхолдеров
+func (q *Query) withArgs() []interface{} {
+	var args []interface{}
+	for _, cte := range q.ctes {
+		args = append(args, cte.args...)
+	}
+	return args
+}
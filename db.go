@@ -3,83 +3,129 @@ package chorm
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"reflect"
 	"strings"
 	"time"
 )
 
-// Connect создает подключение к ClickHouse
-func Connect(ctx context.Context, config Config) (*DB, error) {
-	if config.Port == 0 {
-		config.Port = 9000
-	}
-	if config.MaxOpenConns == 0 {
-		config.MaxOpenConns = 10
-	}
-	if config.MaxIdleConns == 0 {
-		config.MaxIdleConns = 5
-	}
-	if config.ConnMaxLifetime == 0 {
-		config.ConnMaxLifetime = time.Hour
-	}
+// Connect создает подключение к ClickHouse. Принимает Config или
+// функциональные опции:
+//
+//	db, err := chorm.Connect(ctx, chorm.WithHost("localhost"), chorm.WithDatabase("analytics"))
+func Connect(ctx context.Context, opts ...Option) (*DB, error) {
+	config := newConfig(opts)
+	config.setDefaults()
 
-	// Создаем DSN для подключения
-	dsn := fmt.Sprintf("clickhouse://%s:%s@%s:%d/%s?dial_timeout=10s&max_execution_time=60",
-		config.Username, config.Password, config.Host, config.Port, config.Database)
-
-	if config.TLS {
-		dsn += "&secure=true"
+	db := &DB{
+		config: config,
+		state:  &connState{},
 	}
-
-	if config.Compression {
-		dsn += "&compress=true"
+	if err := db.EnsureConnected(ctx); err != nil {
+		return nil, err
 	}
 
-	// Подключаемся к базе данных
-	conn, err := sql.Open("clickhouse", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to ClickHouse: %w", err)
-	}
-
-	// Настраиваем пул соединений
-	conn.SetMaxOpenConns(config.MaxOpenConns)
-	conn.SetMaxIdleConns(config.MaxIdleConns)
-	conn.SetConnMaxLifetime(config.ConnMaxLifetime)
+	return db, nil
+}
 
-	// Проверяем подключение
-	if err := conn.PingContext(ctx); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to ping ClickHouse: %w", err)
-	}
+// ConnectLazy создает DB без подключения к серверу: sql.Open и проверка
+// соединения выполняются при первом запросе или вызове EnsureConnected.
+// Ошибки конфигурации и подключения возвращаются первым запросом
+func ConnectLazy(ctx context.Context, config Config) *DB {
+	config.setDefaults()
 
 	return &DB{
-		conn:   conn,
 		config: config,
-	}, nil
+		state:  &connState{},
+	}
+}
+
+// NewDB создает DB поверх открытого пула database/sql, например с
+// собственным драйвером или в тестах. Пул не проверяется и не настраивается
+// по config, Close закрывает его
+func NewDB(conn *sql.DB, config Config) *DB {
+	config.setDefaults()
+	return &DB{conn: conn, config: config}
 }
 
 // Close закрывает соединение с базой данных
 func (db *DB) Close() error {
+	if db.state != nil {
+		db.stopKeepAlive()
+
+		db.state.mu.Lock()
+		defer db.state.mu.Unlock()
+		if db.state.conn == nil {
+			return nil
+		}
+		return db.state.conn.Close()
+	}
 	return db.conn.Close()
 }
 
-// CreateTable создает таблицу на основе структуры
-func (db *DB) CreateTable(ctx context.Context, model interface{}) error {
+// Stats возвращает статистику пула соединений. Для неподключенной DB,
+// созданной через ConnectLazy, возвращается нулевая статистика
+func (db *DB) Stats() sql.DBStats {
+	if db.state != nil {
+		db.state.mu.Lock()
+		defer db.state.mu.Unlock()
+		if db.state.conn == nil {
+			return sql.DBStats{}
+		}
+		return db.state.conn.Stats()
+	}
+	return db.conn.Stats()
+}
+
+// Host возвращает адрес сервера из конфигурации
+func (db *DB) Host() string {
+	return db.config.Host
+}
+
+// Database возвращает базу данных DB: заданную UseDatabase, иначе из
+// конфигурации
+func (db *DB) Database() string {
+	if db.database != "" {
+		return db.database
+	}
+	return db.config.Database
+}
+
+// CreateTableSQL возвращает DDL, который выполнил бы CreateTable, без
+// обращения к серверу: для ревью схемы или файлов миграций
+func (db *DB) CreateTableSQL(model interface{}) (string, error) {
 	mapper := NewMapper()
 	info, err := mapper.ParseStruct(model)
 	if err != nil {
-		return fmt.Errorf("failed to parse struct: %w", err)
+		return "", fmt.Errorf("failed to parse struct: %w", err)
 	}
 
-	sql := mapper.BuildCreateTableSQL(info)
-
-	if db.config.Debug {
-		fmt.Printf("Creating table with SQL: %s\n", sql)
+	// Движок из тега ch_engine имеет приоритет над Config.DefaultEngine
+	if (info.Engine == "" && db.config.DefaultEngine != "") || db.database != "" {
+		table := *info
+		if table.Engine == "" {
+			table.Engine = db.config.DefaultEngine
+		}
+		table.database = db.database
+		info = &table
 	}
 
-	_, err = db.conn.ExecContext(ctx, sql)
+	return mapper.BuildCreateTableSQL(info), nil
+}
+
+// CreateTable создает таблицу на основе структуры
+func (db *DB) CreateTable(ctx context.Context, model interface{}) error {
+	sql, err := db.CreateTableSQL(model)
 	if err != nil {
+		return err
+	}
+
+	db.debugf("CreateTable SQL: %s", sql)
+
+	ctx, event := db.beforeQuery(ctx, sql, nil)
+	_, err = db.execConn(ctx, event)
+	if err := db.finishQuery(ctx, event, 0, err); err != nil {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
@@ -93,6 +139,11 @@ func (db *DB) Insert(ctx context.Context, model interface{}) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse struct: %w", err)
 	}
+	model = db.applyTimestamps(info, model, time.Now(), true)
+	model = applyAudit(info, model, db.auditUser(ctx), true)
+	if err := validateModel(info, model); err != nil {
+		return err
+	}
 
 	// Получаем значения полей
 	var columns []string
@@ -100,26 +151,29 @@ func (db *DB) Insert(ctx context.Context, model interface{}) error {
 	var placeholders []string
 
 	for _, field := range info.Fields {
-		value, err := mapper.GetFieldValue(model, field.Name)
+		if !field.insertable() {
+			continue
+		}
+
+		value, err := mapper.GetFieldValue(model, field.FieldName)
 		if err != nil {
 			continue // Пропускаем поля, которые не удалось получить
 		}
 
 		columns = append(columns, fmt.Sprintf("`%s`", field.Name))
-		values = append(values, value)
+		values = append(values, sensitiveArg(field, unixTimeArg(field, tupleArg(value))))
 		placeholders = append(placeholders, "?")
 	}
 
-	sql := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s)",
-		info.Name, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		db.quoteTable(info.Name), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
 
-	if db.config.Debug {
-		fmt.Printf("Insert SQL: %s\n", sql)
-		fmt.Printf("Values: %v\n", values)
-	}
+	db.debugf("Insert SQL: %s", sql)
+	db.debugf("Insert Values: %v", values)
 
-	_, err = db.conn.ExecContext(ctx, sql, values...)
-	if err != nil {
+	ctx, event := db.beforeQuery(ctx, sql, values)
+	_, err = db.execConn(ctx, event)
+	if err := db.finishQuery(ctx, event, 1, err); err != nil {
 		return fmt.Errorf("failed to insert record: %w", err)
 	}
 
@@ -140,27 +194,39 @@ func (db *DB) InsertBatch(ctx context.Context, models []interface{}) error {
 
 	// Получаем колонки из первой модели
 	var columns []string
+	var fields []FieldInfo
 	for _, field := range info.Fields {
+		if !field.insertable() {
+			continue
+		}
 		columns = append(columns, fmt.Sprintf("`%s`", field.Name))
+		fields = append(fields, field)
 	}
 
 	// Строим SQL для batch insert
-	sql := fmt.Sprintf("INSERT INTO `%s` (%s) VALUES ",
-		info.Name, strings.Join(columns, ", "))
+	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES ",
+		db.quoteTable(info.Name), strings.Join(columns, ", "))
 
 	var allValues []interface{}
 	var valueGroups []string
 
-	for _, model := range models {
+	now, user := time.Now(), db.auditUser(ctx)
+	for i, model := range models {
+		model = db.applyTimestamps(info, model, now, true)
+		model = applyAudit(info, model, user, true)
+		if err := validateModel(info, model); err != nil {
+			return fmt.Errorf("invalid record %d: %w", i, err)
+		}
+
 		var values []interface{}
 		var placeholders []string
 
-		for _, field := range info.Fields {
-			value, err := mapper.GetFieldValue(model, field.Name)
+		for _, field := range fields {
+			value, err := mapper.GetFieldValue(model, field.FieldName)
 			if err != nil {
 				value = nil // Используем NULL для недоступных полей
 			}
-			values = append(values, value)
+			values = append(values, sensitiveArg(field, unixTimeArg(field, tupleArg(value))))
 			placeholders = append(placeholders, "?")
 		}
 
@@ -170,59 +236,214 @@ func (db *DB) InsertBatch(ctx context.Context, models []interface{}) error {
 
 	sql += strings.Join(valueGroups, ", ")
 
-	if db.config.Debug {
-		fmt.Printf("Batch Insert SQL: %s\n", sql)
-	}
+	db.debugf("Batch Insert SQL: %s", sql)
 
-	_, err = db.conn.ExecContext(ctx, sql, allValues...)
-	if err != nil {
+	ctx, event := db.beforeQuery(ctx, sql, allValues)
+	_, err = db.execConn(ctx, event)
+	if err := db.finishQuery(ctx, event, int64(len(models)), err); err != nil {
 		return fmt.Errorf("failed to batch insert records: %w", err)
 	}
 
 	return nil
 }
 
+// InsertWithTransform вставляет строки через табличную функцию input():
+// значения передаются в формате inputSchema и преобразуются на сервере
+// выражением selectExpr перед записью в table:
+//
+//	db.InsertWithTransform(ctx, "events", "id String, ts String",
+//		"toUInt64(id), parseDateTimeBestEffort(ts)", rows)
+//
+// Строка - []interface{} со значениями в порядке колонок inputSchema или
+// структура, вставляемые поля которой идут в том же порядке
+func (db *DB) InsertWithTransform(ctx context.Context, table string, inputSchema string, selectExpr string, rows []interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	mapper := NewMapper()
+	var allValues []interface{}
+	var valueGroups []string
+	width := -1
+
+	for i, row := range rows {
+		values, err := transformRowValues(mapper, row)
+		if err != nil {
+			return fmt.Errorf("failed to read row %d: %w", i, err)
+		}
+		if width >= 0 && len(values) != width {
+			return fmt.Errorf("row %d has %d values, expected %d", i, len(values), width)
+		}
+		width = len(values)
+
+		placeholders := make([]string, len(values))
+		for j := range placeholders {
+			placeholders[j] = "?"
+		}
+		valueGroups = append(valueGroups, fmt.Sprintf("(%s)", strings.Join(placeholders, ", ")))
+		allValues = append(allValues, values...)
+	}
+
+	sql := fmt.Sprintf("INSERT INTO %s SELECT %s FROM input(%s) FORMAT Values %s",
+		db.quoteTable(table), selectExpr, quoteString(inputSchema), strings.Join(valueGroups, ", "))
+
+	db.debugf("Insert With Transform SQL: %s", sql)
+
+	ctx, event := db.beforeQuery(ctx, sql, allValues)
+	_, err := db.execConn(ctx, event)
+	if err := db.finishQuery(ctx, event, int64(len(rows)), err); err != nil {
+		return fmt.Errorf("failed to insert with transform: %w", err)
+	}
+
+	return nil
+}
+
+// transformRowValues возвращает значения строки для InsertWithTransform
+func transformRowValues(mapper *Mapper, row interface{}) ([]interface{}, error) {
+	if values, ok := row.([]interface{}); ok {
+		return values, nil
+	}
+
+	info, err := mapper.ParseStruct(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse struct: %w", err)
+	}
+
+	var values []interface{}
+	for _, field := range info.Fields {
+		if !field.insertable() {
+			continue
+		}
+		value, err := mapper.GetFieldValue(row, field.FieldName)
+		if err != nil {
+			value = nil
+		}
+		values = append(values, sensitiveArg(field, unixTimeArg(field, value)))
+	}
+	return values, nil
+}
+
 // Query выполняет запрос и заполняет результат в slice
 func (db *DB) Query(ctx context.Context, result interface{}, query string, args ...interface{}) error {
-	if db.config.Debug {
-		fmt.Printf("Query SQL: %s\n", query)
-		fmt.Printf("Args: %v\n", args)
-	}
+	db.debugf("Query SQL: %s", query)
+	db.debugf("Query Args: %v", args)
 
-	rows, err := db.conn.QueryContext(ctx, query, args...)
+	ctx, event := db.beforeQuery(ctx, query, args)
+	rows, err := db.queryConn(ctx, event)
 	if err != nil {
-		return fmt.Errorf("failed to execute query: %w", err)
+		return fmt.Errorf("failed to execute query: %w", db.finishQuery(ctx, event, 0, err))
 	}
 	defer rows.Close()
 
-	return db.scanRows(rows, result)
+	n, err := db.scanRows(rows, result)
+	db.finishQuery(ctx, event, n, err)
+	return err
 }
 
 // QueryRow выполняет запрос и возвращает одну строку
 func (db *DB) QueryRow(ctx context.Context, result interface{}, query string, args ...interface{}) error {
-	if db.config.Debug {
-		fmt.Printf("QueryRow SQL: %s\n", query)
-		fmt.Printf("Args: %v\n", args)
+	db.debugf("QueryRow SQL: %s", query)
+	db.debugf("QueryRow Args: %v", args)
+
+	ctx, event := db.beforeQuery(ctx, query, args)
+	rows, err := db.queryConn(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", db.finishQuery(ctx, event, 0, err))
 	}
+	defer rows.Close()
 
-	row := db.conn.QueryRowContext(ctx, query, args...)
-	return db.scanRow(row, result)
+	err = db.scanRow(rows, result)
+	db.finishQuery(ctx, event, rowCount(err), err)
+	return err
+}
+
+// FindMany загружает записи по списку первичных ключей в slice структур.
+// Если keepOrder равен true, результат упорядочивается в порядке ids
+func (db *DB) FindMany(ctx context.Context, dest interface{}, ids []interface{}, keepOrder ...bool) error {
+	destVal := reflect.ValueOf(dest)
+	if destVal.Kind() != reflect.Ptr || destVal.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("dest must be a pointer to slice")
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	mapper := NewMapper()
+	elementType := destVal.Elem().Type().Elem()
+	info, err := mapper.ParseStruct(reflect.New(elementType).Interface())
+	if err != nil {
+		return fmt.Errorf("failed to parse struct: %w", err)
+	}
+
+	var pk *FieldInfo
+	for i := range info.Fields {
+		if info.Fields[i].IsPK {
+			pk = &info.Fields[i]
+			break
+		}
+	}
+	if pk == nil {
+		return fmt.Errorf("no primary key found for table %s", info.Name)
+	}
+
+	placeholders := make([]string, len(ids))
+	for i := range ids {
+		placeholders[i] = "?"
+	}
+
+	sql := fmt.Sprintf("SELECT * FROM %s WHERE `%s` IN (%s)",
+		db.quoteTable(info.Name), pk.Name, strings.Join(placeholders, ", "))
+
+	if err := db.Query(ctx, dest, sql, ids...); err != nil {
+		return err
+	}
+
+	if len(keepOrder) > 0 && keepOrder[0] {
+		sortByIDs(destVal.Elem(), pk.FieldName, ids)
+	}
+
+	return nil
+}
+
+// sortByIDs упорядочивает slice структур в порядке следования ids
+func sortByIDs(sliceVal reflect.Value, fieldName string, ids []interface{}) {
+	byID := make(map[string][]reflect.Value, sliceVal.Len())
+	for i := 0; i < sliceVal.Len(); i++ {
+		element := sliceVal.Index(i)
+		if element.Kind() == reflect.Ptr {
+			element = element.Elem()
+		}
+		key := fmt.Sprintf("%v", element.FieldByName(fieldName).Interface())
+		byID[key] = append(byID[key], sliceVal.Index(i))
+	}
+
+	sorted := reflect.MakeSlice(sliceVal.Type(), 0, sliceVal.Len())
+	for _, id := range ids {
+		key := fmt.Sprintf("%v", id)
+		for _, element := range byID[key] {
+			sorted = reflect.Append(sorted, element)
+		}
+		delete(byID, key)
+	}
+
+	sliceVal.Set(sorted)
 }
 
 // Exec выполняет запрос без возврата результата
 func (db *DB) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
-	if db.config.Debug {
-		fmt.Printf("Exec SQL: %s\n", query)
-		fmt.Printf("Args: %v\n", args)
-	}
+	db.debugf("Exec SQL: %s", query)
+	db.debugf("Exec Args: %v", args)
 
-	result, err := db.conn.ExecContext(ctx, query, args...)
+	ctx, event := db.beforeQuery(ctx, query, args)
+	result, err := db.execConn(ctx, event)
 	if err != nil {
-		return Result{}, fmt.Errorf("failed to execute query: %w", err)
+		return Result{}, fmt.Errorf("failed to execute query: %w", db.finishQuery(ctx, event, 0, err))
 	}
 
 	lastInsertID, _ := result.LastInsertId()
 	rowsAffected, _ := result.RowsAffected()
+	db.finishQuery(ctx, event, rowsAffected, nil)
 
 	return Result{
 		LastInsertID: lastInsertID,
@@ -230,11 +451,39 @@ func (db *DB) Exec(ctx context.Context, query string, args ...interface{}) (Resu
 	}, nil
 }
 
-// scanRows сканирует результаты запроса в slice структур
-func (db *DB) scanRows(rows *sql.Rows, result interface{}) error {
+// ExecMulti последовательно выполняет запросы и останавливается на первой
+// ошибке. Ошибка имеет тип *StatementError с номером и текстом запроса
+func (db *DB) ExecMulti(ctx context.Context, statements []string) error {
+	for i, statement := range statements {
+		if _, err := db.Exec(ctx, statement); err != nil {
+			return &StatementError{Index: i, SQL: statement, Err: err}
+		}
+	}
+	return nil
+}
+
+// ExecMultiContinueOnError выполняет все запросы независимо от ошибок и
+// возвращает накопленные ошибки как MultiError из *StatementError
+// (nil, если все запросы выполнены)
+func (db *DB) ExecMultiContinueOnError(ctx context.Context, statements []string) error {
+	var errs MultiError
+	for i, statement := range statements {
+		if _, err := db.Exec(ctx, statement); err != nil {
+			errs = append(errs, &StatementError{Index: i, SQL: statement, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// scanRows сканирует результаты запроса в slice структур и возвращает
+// количество прочитанных строк
+func (db *DB) scanRows(rows *sql.Rows, result interface{}) (int64, error) {
 	resultVal := reflect.ValueOf(result)
 	if resultVal.Kind() != reflect.Ptr || resultVal.Elem().Kind() != reflect.Slice {
-		return fmt.Errorf("result must be a pointer to slice")
+		return 0, fmt.Errorf("result must be a pointer to slice")
 	}
 
 	sliceVal := resultVal.Elem()
@@ -243,7 +492,7 @@ func (db *DB) scanRows(rows *sql.Rows, result interface{}) error {
 	// Получаем колонки
 	columns, err := rows.Columns()
 	if err != nil {
-		return fmt.Errorf("failed to get columns: %w", err)
+		return 0, fmt.Errorf("failed to get columns: %w", err)
 	}
 
 	// Создаем слайс для значений
@@ -253,20 +502,45 @@ func (db *DB) scanRows(rows *sql.Rows, result interface{}) error {
 		valuePtrs[i] = &values[i]
 	}
 
+	// Для map сканируем в типы колонок драйвера, чтобы сохранить нативные типы
+	var scanTypes []reflect.Type
+	if elementType.Kind() == reflect.Map {
+		scanTypes = columnScanTypes(rows, len(columns))
+	}
+
 	// Сканируем каждую строку
+	var n int64
 	for rows.Next() {
+		if scanTypes != nil {
+			for i, scanType := range scanTypes {
+				valuePtrs[i] = reflect.New(scanType).Interface()
+			}
+		}
+
 		err := rows.Scan(valuePtrs...)
 		if err != nil {
-			return fmt.Errorf("failed to scan row: %w", err)
+			return n, fmt.Errorf("failed to scan row: %w", classifyError(err))
 		}
+		n++
 
 		// Создаем новый элемент
 		element := reflect.New(elementType).Elem()
 
 		// Заполняем элемент значениями
-		for i, column := range columns {
-			if i < len(values) {
-				db.setFieldValue(element, column, values[i])
+		if elementType.Kind() == reflect.Map {
+			element = reflect.MakeMapWithSize(elementType, len(columns))
+			for i, column := range columns {
+				value := reflect.ValueOf(valuePtrs[i]).Elem().Interface()
+				if db.rowTransformer != nil {
+					value = db.rowTransformer(column, value)
+				}
+				element.SetMapIndex(reflect.ValueOf(column), reflect.ValueOf(&value).Elem())
+			}
+		} else {
+			for i, column := range columns {
+				if i < len(values) {
+					db.assignColumn(element, column, values[i])
+				}
 			}
 		}
 
@@ -274,64 +548,211 @@ func (db *DB) scanRows(rows *sql.Rows, result interface{}) error {
 		sliceVal.Set(reflect.Append(sliceVal, element))
 	}
 
-	return rows.Err()
+	return n, classifyError(rows.Err())
 }
 
-// scanRow сканирует одну строку результата
-func (db *DB) scanRow(row *sql.Row, result interface{}) error {
+// columnScanTypes возвращает Go типы, в которые драйвер сканирует колонки
+// (например, []string для Array(String), time.Time для DateTime, *string для
+// Nullable(String)). Если драйвер не сообщает тип, используется interface{}
+func columnScanTypes(rows *sql.Rows, count int) []reflect.Type {
+	anyType := reflect.TypeOf((*interface{})(nil)).Elem()
+
+	scanTypes := make([]reflect.Type, count)
+	for i := range scanTypes {
+		scanTypes[i] = anyType
+	}
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return scanTypes
+	}
+
+	for i, columnType := range columnTypes {
+		if i >= count {
+			break
+		}
+		// sql.RawBytes нельзя сохранять после Next
+		if scanType := columnType.ScanType(); scanType != nil && scanType != reflect.TypeOf(sql.RawBytes{}) {
+			scanTypes[i] = scanType
+		}
+	}
+
+	return scanTypes
+}
+
+// scanRow сканирует первую строку результата в структуру или скалярное значение
+func (db *DB) scanRow(rows *sql.Rows, result interface{}) error {
 	resultVal := reflect.ValueOf(result)
 	if resultVal.Kind() != reflect.Ptr {
 		return fmt.Errorf("result must be a pointer")
 	}
 
-	// Получаем тип результата
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to scan row: %w", classifyError(err))
+		}
+		return ErrNotFound
+	}
+
+	// Скалярный результат (например, COUNT(*)) сканируем напрямую
 	resultType := resultVal.Type().Elem()
-	if resultType.Kind() != reflect.Struct {
-		return fmt.Errorf("result must be a pointer to struct")
+	if resultType.Kind() != reflect.Struct || resultType == reflect.TypeOf(time.Time{}) {
+		if err := rows.Scan(result); err != nil {
+			return fmt.Errorf("failed to scan row: %w", classifyError(err))
+		}
+		return nil
 	}
 
-	// Создаем временную структуру для получения колонок
-	temp := reflect.New(resultType).Interface()
-	mapper := NewMapper()
-	info, err := mapper.ParseStruct(temp)
+	// Получаем колонки
+	columns, err := rows.Columns()
 	if err != nil {
-		return fmt.Errorf("failed to parse struct: %w", err)
+		return fmt.Errorf("failed to get columns: %w", err)
 	}
 
 	// Создаем слайс для значений
-	values := make([]interface{}, len(info.Fields))
+	values := make([]interface{}, len(columns))
 	valuePtrs := make([]interface{}, len(values))
 	for i := range values {
 		valuePtrs[i] = &values[i]
 	}
 
 	// Сканируем строку
-	err = row.Scan(valuePtrs...)
-	if err != nil {
-		return fmt.Errorf("failed to scan row: %w", err)
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return fmt.Errorf("failed to scan row: %w", classifyError(err))
 	}
 
 	// Заполняем результат
 	element := resultVal.Elem()
-	for i, field := range info.Fields {
-		if i < len(values) {
-			db.setFieldValue(element, field.Name, values[i])
-		}
+	for i, column := range columns {
+		db.assignColumn(element, column, values[i])
 	}
 
 	return nil
 }
 
+// rowCount возвращает количество строк, прочитанных scanRow
+func rowCount(err error) int64 {
+	if err != nil {
+		return 0
+	}
+	return 1
+}
+
+// WithRowTransformer возвращает копию DB, которая пропускает каждое значение
+// колонки через fn перед записью в поле структуры
+func (db *DB) WithRowTransformer(fn RowTransformer) *DB {
+	clone := *db
+	clone.rowTransformer = fn
+	return &clone
+}
+
+// UseDatabase возвращает копию DB, которая уточняет неуточненные имена
+// таблиц базой database: таблицы запросов NewQuery и Model, а также SQL,
+// построенный по моделям (Insert, InsertBatch, CreateTable, Save и другие).
+// Соединение и пул общие с исходной DB, поэтому одно подключение работает
+// с несколькими базами. Имена с точкой, табличные функции и SQL в Exec,
+// Query и Join не изменяются:
+//
+//	logs := db.UseDatabase("logs")
+//	err := logs.Insert(ctx, &event) // INSERT INTO `logs`.`events` ...
+func (db *DB) UseDatabase(database string) *DB {
+	clone := *db
+	clone.database = database
+	return &clone
+}
+
+// quoteTable возвращает имя таблицы модели в обратных кавычках, уточненное
+// базой UseDatabase
+func (db *DB) quoteTable(name string) string {
+	return quoteTable(db.database, name)
+}
+
+// quoteTable возвращает `name` или `database`.`name`, если база задана
+func quoteTable(database, name string) string {
+	if database == "" {
+		return "`" + name + "`"
+	}
+	return "`" + database + "`.`" + name + "`"
+}
+
+// splitTable разделяет имя вида database.table или `database`.`table` на базу
+// и таблицу без обратных кавычек. Для имени без базы database пуста
+func splitTable(table string) (database, name string) {
+	if i := strings.Index(table, "."); i >= 0 {
+		database, table = table[:i], table[i+1:]
+	}
+	return strings.Trim(database, "`"), strings.Trim(table, "`")
+}
+
+// qualifyTable уточняет базой UseDatabase имя таблицы запроса, если оно
+// не содержит базу, и не изменяет табличные функции и подзапросы
+func (db *DB) qualifyTable(table string) string {
+	if db == nil || db.database == "" || table == "" || strings.ContainsAny(table, ". ()") {
+		return table
+	}
+	if strings.HasPrefix(table, "`") {
+		return "`" + db.database + "`." + table
+	}
+	return db.database + "." + table
+}
+
+// assignColumn применяет преобразователь строк и устанавливает значение поля
+func (db *DB) assignColumn(element reflect.Value, column string, value interface{}) {
+	if db.rowTransformer != nil {
+		value = db.rowTransformer(column, value)
+	}
+	db.setFieldValue(element, column, value)
+}
+
 // setFieldValue устанавливает значение поля в структуре
 func (db *DB) setFieldValue(element reflect.Value, fieldName string, value interface{}) {
-	field := element.FieldByName(fieldName)
+	field := fieldByColumn(element, fieldName)
 	if !field.IsValid() || !field.CanSet() {
 		return
 	}
 
+	setValue(field, driverValue(value))
+}
+
+// driverValue раскрывает значения Nullable колонок, которые драйвер
+// возвращает как указатели (*time.Time, *string) или sql.Null* типы.
+// NULL становится nil
+func driverValue(value interface{}) interface{} {
+	if valuer, ok := value.(driver.Valuer); ok {
+		inner, err := valuer.Value()
+		if err != nil {
+			return nil
+		}
+		return inner
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		return rv.Elem().Interface()
+	}
+	return value
+}
+
+// setValue конвертирует значение в тип поля. Поле-указатель становится nil
+// для NULL и указывает на сконвертированное значение в остальных случаях
+func setValue(field reflect.Value, value interface{}) {
 	// Конвертируем значение в нужный тип
 	fieldType := field.Type()
 
+	if fieldType.Kind() == reflect.Ptr {
+		if value == nil {
+			field.Set(reflect.Zero(fieldType))
+			return
+		}
+		ptr := reflect.New(fieldType.Elem())
+		setValue(ptr.Elem(), value)
+		field.Set(ptr)
+		return
+	}
+
 	switch fieldType.Kind() {
 	case reflect.String:
 		if value != nil {
@@ -394,14 +815,30 @@ func (db *DB) setFieldValue(element reflect.Value, fieldName string, value inter
 				field.SetBool(b)
 			}
 		}
+	case reflect.Struct:
+		if t, ok := value.(time.Time); ok && fieldType == reflect.TypeOf(time.Time{}) {
+			field.Set(reflect.ValueOf(t))
+		} else if t, ok := unixTimeValue(value); ok && fieldType == reflect.TypeOf(time.Time{}) {
+			field.Set(reflect.ValueOf(t))
+		} else if isTupleStruct(fieldType) && value != nil {
+			setTupleValue(field, value)
+		}
+	case reflect.Slice:
+		if value != nil {
+			setSliceValue(field, value)
+		}
 	}
 }
 
 // Begin начинает транзакцию
 func (db *DB) Begin(ctx context.Context) (*Tx, error) {
-	tx, err := db.conn.BeginTx(ctx, nil)
+	var tx *sql.Tx
+	err := db.withConn(ctx, true, func(conn *sql.DB) (err error) {
+		tx, err = conn.BeginTx(ctx, nil)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", classifyError(err))
 	}
 
 	return &Tx{tx: tx, db: db}, nil
@@ -423,15 +860,72 @@ func (tx *Tx) Rollback() error {
 	return tx.tx.Rollback()
 }
 
+// bound возвращает копию DB, выполняющую запросы в транзакции
+func (tx *Tx) bound() *DB {
+	db := *tx.db
+	db.tx = tx.tx
+	return &db
+}
+
+// Query выполняет запрос в транзакции и заполняет результат в slice. Запрос
+// видит данные, записанные ранее в этой транзакции
+func (tx *Tx) Query(ctx context.Context, result interface{}, query string, args ...interface{}) error {
+	return tx.bound().Query(ctx, result, query, args...)
+}
+
+// QueryRow выполняет запрос в транзакции и возвращает одну строку
+func (tx *Tx) QueryRow(ctx context.Context, result interface{}, query string, args ...interface{}) error {
+	return tx.bound().QueryRow(ctx, result, query, args...)
+}
+
+// NewQuery создает построитель запросов, выполняющий запросы в транзакции
+func (tx *Tx) NewQuery() *Query {
+	return tx.bound().NewQuery()
+}
+
+// Insert вставляет одну запись в транзакции
+func (tx *Tx) Insert(ctx context.Context, model interface{}) error {
+	return tx.bound().Insert(ctx, model)
+}
+
+// InsertBatch вставляет множество записей в транзакции
+func (tx *Tx) InsertBatch(ctx context.Context, models []interface{}) error {
+	return tx.bound().InsertBatch(ctx, models)
+}
+
+// errSavepointsNotSupported возвращается методами savepoint: транзакции
+// ClickHouse не поддерживают SAVEPOINT
+var errSavepointsNotSupported = fmt.Errorf("savepoints are not supported by ClickHouse transactions: %w", ErrNotSupported)
+
+// Savepoint создает точку сохранения. ClickHouse не поддерживает точки
+// сохранения, поэтому метод сразу возвращает ошибку ErrNotSupported. Методы
+// savepoint нужны для единого интерфейса транзакций с другими СУБД
+func (tx *Tx) Savepoint(ctx context.Context, name string) error {
+	return errSavepointsNotSupported
+}
+
+// ReleaseSavepoint удаляет точку сохранения. Всегда возвращает ErrNotSupported
+func (tx *Tx) ReleaseSavepoint(ctx context.Context, name string) error {
+	return errSavepointsNotSupported
+}
+
+// RollbackToSavepoint откатывает транзакцию до точки сохранения. Всегда
+// возвращает ErrNotSupported
+func (tx *Tx) RollbackToSavepoint(ctx context.Context, name string) error {
+	return errSavepointsNotSupported
+}
+
 // Exec выполняет запрос в транзакции
 func (tx *Tx) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
-	result, err := tx.tx.ExecContext(ctx, query, args...)
+	ctx, event := tx.db.beforeQuery(ctx, query, args)
+	result, err := tx.tx.ExecContext(ctx, event.SQL, driverArgs(event.Args)...)
 	if err != nil {
-		return Result{}, fmt.Errorf("failed to execute query in transaction: %w", err)
+		return Result{}, fmt.Errorf("failed to execute query in transaction: %w", tx.db.finishQuery(ctx, event, 0, err))
 	}
 
 	lastInsertID, _ := result.LastInsertId()
 	rowsAffected, _ := result.RowsAffected()
+	tx.db.finishQuery(ctx, event, rowsAffected, nil)
 
 	return Result{
 		LastInsertID: lastInsertID,
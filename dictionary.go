@@ -0,0 +1,179 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DictionarySourceType представляет тип источника словаря
+type DictionarySourceType string
+
+const (
+	DictionarySourceClickHouse DictionarySourceType = "CLICKHOUSE"
+	DictionarySourceHTTP       DictionarySourceType = "HTTP"
+	DictionarySourceFile       DictionarySourceType = "FILE"
+)
+
+// DictionaryLayout представляет способ хранения словаря в памяти
+type DictionaryLayout string
+
+const (
+	DictionaryLayoutFlat             DictionaryLayout = "FLAT"
+	DictionaryLayoutHashed           DictionaryLayout = "HASHED"
+	DictionaryLayoutComplexKeyHashed DictionaryLayout = "COMPLEX_KEY_HASHED"
+)
+
+// DictionarySource описывает источник данных словаря
+type DictionarySource struct {
+	Type   DictionarySourceType
+	Params map[string]string // Например: HOST, PORT, TABLE, URL, PATH, FORMAT
+}
+
+// DictionaryAttribute описывает атрибут (колонку) словаря
+type DictionaryAttribute struct {
+	Name         string
+	Type         string
+	Default      string // Выражение DEFAULT
+	Expression   string // Выражение EXPRESSION
+	Hierarchical bool
+	Injective    bool
+}
+
+// DictionaryDef описывает внешний словарь ClickHouse
+type DictionaryDef struct {
+	Name        string
+	Database    string
+	PrimaryKey  []string
+	Attributes  []DictionaryAttribute
+	Source      DictionarySource
+	Layout      DictionaryLayout
+	LifetimeMin int // Секунды
+	LifetimeMax int // Секунды
+}
+
+// validate проверяет обязательные части определения словаря
+func (d DictionaryDef) validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("dictionary name is required")
+	}
+	if len(d.PrimaryKey) == 0 {
+		return fmt.Errorf("dictionary %s: primary key is required", d.Name)
+	}
+	if len(d.Attributes) == 0 {
+		return fmt.Errorf("dictionary %s: at least one attribute is required", d.Name)
+	}
+	if d.Source.Type == "" {
+		return fmt.Errorf("dictionary %s: source type is required", d.Name)
+	}
+	return nil
+}
+
+// BuildCreateSQL строит SQL для создания словаря
+func (d DictionaryDef) BuildCreateSQL() string {
+	var parts []string
+
+	name := d.Name
+	if d.Database != "" {
+		name = d.Database + "." + d.Name
+	}
+	parts = append(parts, fmt.Sprintf("CREATE DICTIONARY IF NOT EXISTS %s (", name))
+
+	// Attributes
+	var attrs []string
+	for _, attr := range d.Attributes {
+		def := fmt.Sprintf("%s %s", attr.Name, attr.Type)
+		if attr.Default != "" {
+			def += " DEFAULT " + attr.Default
+		}
+		if attr.Expression != "" {
+			def += " EXPRESSION " + attr.Expression
+		}
+		if attr.Hierarchical {
+			def += " HIERARCHICAL"
+		}
+		if attr.Injective {
+			def += " INJECTIVE"
+		}
+		attrs = append(attrs, def)
+	}
+	parts = append(parts, "  "+strings.Join(attrs, ",\n  "))
+	parts = append(parts, ")")
+
+	// PRIMARY KEY
+	parts = append(parts, fmt.Sprintf("PRIMARY KEY %s", strings.Join(d.PrimaryKey, ", ")))
+
+	// SOURCE
+	keys := make([]string, 0, len(d.Source.Params))
+	for k := range d.Source.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, k := range keys {
+		params = append(params, fmt.Sprintf("%s %s", strings.ToUpper(k), dictionaryParamValue(d.Source.Params[k])))
+	}
+	parts = append(parts, fmt.Sprintf("SOURCE(%s(%s))", d.Source.Type, strings.Join(params, " ")))
+
+	// LAYOUT
+	layout := d.Layout
+	if layout == "" {
+		layout = DictionaryLayoutHashed
+	}
+	parts = append(parts, fmt.Sprintf("LAYOUT(%s())", layout))
+
+	// LIFETIME
+	if d.LifetimeMax > 0 {
+		parts = append(parts, fmt.Sprintf("LIFETIME(MIN %d MAX %d)", d.LifetimeMin, d.LifetimeMax))
+	} else {
+		parts = append(parts, fmt.Sprintf("LIFETIME(%d)", d.LifetimeMin))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// dictionaryParamValue форматирует значение параметра источника словаря
+func dictionaryParamValue(value string) string {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return value
+	}
+	return quoteString(value)
+}
+
+// quoteString экранирует строку как строковый литерал ClickHouse
+func quoteString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// CreateDictionary создает словарь
+func (s *Schema) CreateDictionary(ctx context.Context, def DictionaryDef) error {
+	if err := def.validate(); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(ctx, def.BuildCreateSQL())
+	return err
+}
+
+// DropDictionary удаляет словарь
+func (s *Schema) DropDictionary(ctx context.Context, name string) error {
+	sql := fmt.Sprintf("DROP DICTIONARY IF EXISTS %s", name)
+	_, err := s.db.Exec(ctx, sql)
+	return err
+}
+
+// DictGet возвращает выражение dictGet для использования в Select
+func DictGet(dictionary, attribute, keyExpr string) string {
+	return fmt.Sprintf("dictGet(%s, %s, %s)", quoteString(dictionary), quoteString(attribute), keyExpr)
+}
+
+// DictGetOrDefault возвращает выражение dictGetOrDefault для использования в Select
+func DictGetOrDefault(dictionary, attribute, keyExpr, defaultExpr string) string {
+	return fmt.Sprintf("dictGetOrDefault(%s, %s, %s, %s)",
+		quoteString(dictionary), quoteString(attribute), keyExpr, defaultExpr)
+}
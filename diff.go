@@ -0,0 +1,168 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ResultDiff описывает различия результатов двух запросов
+type ResultDiff struct {
+	RowsBefore    int                      // Количество строк первого запроса
+	RowsAfter     int                      // Количество строк второго запроса
+	AddedRows     []map[string]interface{} // Строки, которые есть только во втором результате
+	RemovedRows   []map[string]interface{} // Строки, которые есть только в первом результате
+	ChangedValues []ValueChange            // Изменившиеся значения сопоставленных строк
+}
+
+// ValueChange описывает изменившееся значение колонки
+type ValueChange struct {
+	Row    int                    // Номер строки в первом результате
+	Key    map[string]interface{} // Значения ключевых колонок (DiffBy)
+	Column string
+	Before interface{}
+	After  interface{}
+}
+
+// Empty сообщает, что результаты совпадают
+func (d *ResultDiff) Empty() bool {
+	return d.RowsBefore == d.RowsAfter && len(d.AddedRows) == 0 &&
+		len(d.RemovedRows) == 0 && len(d.ChangedValues) == 0
+}
+
+// Diff выполняет оба запроса через db и сравнивает результаты: количество
+// строк и значения колонок. Строки сопоставляются по порядку, поэтому запросы
+// должны сортировать результат (ORDER BY); лишние строки второго результата
+// попадают в AddedRows, первого - в RemovedRows. Для сопоставления по
+// ключевым колонкам используйте DiffBy:
+//
+//	counts := func(table string) *chorm.Query {
+//		return db.NewQuery().Table(table).Select("status", "count() AS n").GroupBy("status").OrderBy("status")
+//	}
+//	diff, err := chorm.Diff(ctx, db, counts("orders_backup"), counts("orders"))
+func Diff(ctx context.Context, db *DB, q1, q2 *Query) (*ResultDiff, error) {
+	return DiffBy(ctx, db, q1, q2)
+}
+
+// DiffBy выполняет оба запроса через db и сравнивает результаты, сопоставляя
+// строки по значениям колонок keys. Без keys строки сопоставляются по порядку,
+// как в Diff. Повторяющийся ключ в результате возвращает ошибку
+func DiffBy(ctx context.Context, db *DB, q1, q2 *Query, keys ...string) (*ResultDiff, error) {
+	before, err := diffQuery(ctx, db, q1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run first query: %w", err)
+	}
+	after, err := diffQuery(ctx, db, q2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run second query: %w", err)
+	}
+	return diffRows(before, after, keys)
+}
+
+// diffQuery выполняет запрос и возвращает строки как map
+func diffQuery(ctx context.Context, db *DB, q *Query) ([]map[string]interface{}, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	sql, args := q.ToSQL()
+
+	var rows []map[string]interface{}
+	if err := db.Query(ctx, &rows, sql, args...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// diffRows сравнивает строки двух результатов
+func diffRows(before, after []map[string]interface{}, keys []string) (*ResultDiff, error) {
+	diff := &ResultDiff{RowsBefore: len(before), RowsAfter: len(after)}
+
+	if len(keys) == 0 {
+		for i := 0; i < len(before) && i < len(after); i++ {
+			diff.ChangedValues = append(diff.ChangedValues, diffValues(i, nil, before[i], after[i])...)
+		}
+		if len(after) > len(before) {
+			diff.AddedRows = after[len(before):]
+		}
+		if len(before) > len(after) {
+			diff.RemovedRows = before[len(after):]
+		}
+		return diff, nil
+	}
+
+	afterByKey := make(map[string]int, len(after))
+	for i, row := range after {
+		key := diffKey(row, keys)
+		if _, ok := afterByKey[key]; ok {
+			return nil, fmt.Errorf("duplicate key %s in second result", key)
+		}
+		afterByKey[key] = i
+	}
+
+	seen := make(map[string]bool, len(before))
+	for i, row := range before {
+		key := diffKey(row, keys)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key %s in first result", key)
+		}
+		seen[key] = true
+
+		j, ok := afterByKey[key]
+		if !ok {
+			diff.RemovedRows = append(diff.RemovedRows, row)
+			continue
+		}
+		keyValues := make(map[string]interface{}, len(keys))
+		for _, k := range keys {
+			keyValues[k] = row[k]
+		}
+		diff.ChangedValues = append(diff.ChangedValues, diffValues(i, keyValues, row, after[j])...)
+	}
+
+	for _, row := range after {
+		if !seen[diffKey(row, keys)] {
+			diff.AddedRows = append(diff.AddedRows, row)
+		}
+	}
+	return diff, nil
+}
+
+// diffValues сравнивает значения колонок двух строк в порядке имен колонок
+func diffValues(row int, key map[string]interface{}, before, after map[string]interface{}) []ValueChange {
+	columns := make([]string, 0, len(before))
+	for column := range before {
+		columns = append(columns, column)
+	}
+	for column := range after {
+		if _, ok := before[column]; !ok {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+
+	var changes []ValueChange
+	for _, column := range columns {
+		if reflect.DeepEqual(before[column], after[column]) {
+			continue
+		}
+		changes = append(changes, ValueChange{
+			Row:    row,
+			Key:    key,
+			Column: column,
+			Before: before[column],
+			After:  after[column],
+		})
+	}
+	return changes
+}
+
+// diffKey возвращает строковое представление значений ключевых колонок строки
+func diffKey(row map[string]interface{}, keys []string) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%#v", k, row[k])
+	}
+	return strings.Join(parts, ", ")
+}
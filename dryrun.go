@@ -0,0 +1,220 @@
+package chorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// dryRunPreviewLength ограничивает длину превью SQL в Markdown отчете
+const dryRunPreviewLength = 80
+
+// DryRunMigration описывает миграцию, которая была бы применена. В
+// MigrateDryRun SQL содержит записанные изменяющие запросы Up, в Plan -
+// выражения SQL-миграции; для Go-миграций в Plan он пуст, так как их запросы
+// известны только при выполнении
+type DryRunMigration struct {
+	Name     string
+	SQL      []string
+	Checksum string
+}
+
+// DryRunResult содержит результат пробного запуска миграций
+type DryRunResult struct {
+	WouldApply []DryRunMigration
+}
+
+// dryRunRecorder накапливает изменяющие запросы вместо их выполнения
+type dryRunRecorder struct {
+	statements []string
+}
+
+// execConn выполняет изменяющий запрос на пуле соединений или в транзакции
+// DB. В режиме пробного запуска запрос только записывается
+func (db *DB) execConn(ctx context.Context, event *QueryEvent) (sql.Result, error) {
+	if db.dryRun != nil {
+		db.dryRun.statements = append(db.dryRun.statements, event.SQL)
+		return driver.RowsAffected(0), nil
+	}
+	if db.tx != nil {
+		return db.tx.ExecContext(ctx, event.SQL, driverArgs(event.Args)...)
+	}
+
+	var result sql.Result
+	err := db.withConn(ctx, event.readOnly(), func(conn *sql.DB) (err error) {
+		result, err = conn.ExecContext(ctx, event.SQL, driverArgs(event.Args)...)
+		return err
+	})
+	return result, err
+}
+
+// queryConn выполняет читающий запрос на пуле соединений или в транзакции DB
+func (db *DB) queryConn(ctx context.Context, event *QueryEvent) (*sql.Rows, error) {
+	if db.tx != nil {
+		return db.tx.QueryContext(ctx, event.SQL, driverArgs(event.Args)...)
+	}
+
+	var rows *sql.Rows
+	err := db.withConn(ctx, event.readOnly(), func(conn *sql.DB) (err error) {
+		rows, err = conn.QueryContext(ctx, event.SQL, driverArgs(event.Args)...)
+		return err
+	})
+	return rows, err
+}
+
+// DryRun возвращает копию мигратора в режиме пробного запуска: Migrate,
+// MigrateTo и Steps копии журналируют миграции, которые были бы применены, и
+// их SQL, ничего не выполняя. Таблица миграций не создается и не изменяется,
+// исходный мигратор продолжает применять миграции:
+//
+//	err := migrator.DryRun().Migrate(ctx)
+func (m *Migrator) DryRun() *Migrator {
+	clone := *m
+	clone.migrations = append([]MigrationRecord(nil), m.migrations...)
+	clone.dryRun = true
+	return &clone
+}
+
+// Plan возвращает непримененные миграции в порядке применения. Миграции не
+// выполняются, таблица миграций не создается и не изменяется
+func (m *Migrator) Plan(ctx context.Context) ([]DryRunMigration, error) {
+	pending, _, err := m.pendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return plannedMigrations(pending), nil
+}
+
+// plannedMigrations строит план миграций
+func plannedMigrations(migrations []MigrationRecord) []DryRunMigration {
+	plan := make([]DryRunMigration, len(migrations))
+	for i, migration := range migrations {
+		plan[i] = DryRunMigration{
+			Name:     migration.Name,
+			Checksum: migration.Checksum,
+		}
+		if migration.UpSQL != "" {
+			plan[i].SQL = SplitStatements(migration.UpSQL)
+		}
+	}
+	return plan
+}
+
+// logPlan журналирует миграции, которые были бы применены
+func (m *Migrator) logPlan(plan []DryRunMigration) {
+	if len(plan) == 0 {
+		m.db.infof("Dry run: no pending migrations")
+		return
+	}
+	for _, migration := range plan {
+		if migration.SQL == nil {
+			m.db.infof("Dry run: would apply migration %s (Go migration, statements are not known in advance)", migration.Name)
+			continue
+		}
+		m.db.infof("Dry run: would apply migration %s", migration.Name)
+		for _, statement := range migration.SQL {
+			m.db.infof("Dry run:   %s", statement)
+		}
+	}
+}
+
+// pendingMigrations возвращает непримененные миграции и все миграции в
+// порядке применения, не создавая таблицу миграций. Расхождения таблицы
+// миграций с кодом возвращаются ошибкой, как в Migrate
+func (m *Migrator) pendingMigrations(ctx context.Context) (pending, migrations []MigrationRecord, err error) {
+	var exists uint64
+	err = m.db.QueryRow(ctx, &exists,
+		"SELECT count() FROM system.tables WHERE database = currentDatabase() AND name = ?",
+		(&Migration{}).TableName())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check migrations table: %w", err)
+	}
+
+	var applied []Migration
+	if exists > 0 {
+		if applied, err = m.GetAppliedMigrations(ctx); err != nil {
+			return nil, nil, fmt.Errorf("failed to get applied migrations: %w", err)
+		}
+		if err := m.validateChecksums(applied); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if migrations, err = m.sortedMigrations(); err != nil {
+		return nil, nil, err
+	}
+
+	pending = unappliedMigrations(applied, migrations)
+	if err := m.checkOrder(applied, migrations); err != nil {
+		return nil, nil, err
+	}
+	return pending, migrations, nil
+}
+
+// MigrateDryRun выполняет Up непримененных миграций в режиме записи: изменяющие
+// запросы (Exec, Insert, CreateTable) не выполняются, а собираются в результат.
+// Читающие запросы внутри Up выполняются как обычно. Таблица миграций не
+// создается и не изменяется
+func (m *Migrator) MigrateDryRun(ctx context.Context) (*DryRunResult, error) {
+	pending, _, err := m.pendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DryRunResult{}
+	for _, migration := range pending {
+		recorder := &dryRunRecorder{}
+		db := *m.db
+		db.dryRun = recorder
+
+		if err := migration.Up(ctx, &db); err != nil {
+			return nil, fmt.Errorf("failed to dry-run migration %s: %w", migration.Name, err)
+		}
+
+		result.WouldApply = append(result.WouldApply, DryRunMigration{
+			Name:     migration.Name,
+			SQL:      recorder.statements,
+			Checksum: migration.Checksum,
+		})
+	}
+
+	return result, nil
+}
+
+// Markdown форматирует результат пробного запуска как Markdown таблицу,
+// например для комментария к pull request в CI
+func (r *DryRunResult) Markdown() string {
+	if len(r.WouldApply) == 0 {
+		return "No pending migrations.\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("| Migration | Statements | First statement |\n")
+	b.WriteString("|-----------|------------|-----------------|\n")
+
+	for _, migration := range r.WouldApply {
+		preview := ""
+		if len(migration.SQL) > 0 {
+			preview = markdownCode(markdownCell(truncateText(migration.SQL[0], dryRunPreviewLength)))
+		}
+		fmt.Fprintf(&b, "| %s | %d | %s |\n", markdownCell(migration.Name), len(migration.SQL), preview)
+	}
+
+	return b.String()
+}
+
+// markdownCell экранирует значение для ячейки Markdown таблицы
+func markdownCell(value string) string {
+	return strings.ReplaceAll(value, "|", `\|`)
+}
+
+// markdownCode оформляет значение как inline-код; значения с обратными
+// кавычками оборачиваются в двойные
+func markdownCode(value string) string {
+	if strings.Contains(value, "`") {
+		return "`` " + value + " ``"
+	}
+	return "`" + value + "`"
+}
@@ -0,0 +1,123 @@
+package chorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// ErrTimeout сообщает, что операция прервана по таймауту: подключения,
+// чтения/записи, контекста или max_execution_time на сервере
+var ErrTimeout = errors.New("timeout exceeded")
+
+// ErrNotFound возвращается QueryRow, Get и First, если запрос не вернул ни
+// одной строки. Оборачивает sql.ErrNoRows, поэтому работают обе проверки:
+// errors.Is(err, ErrNotFound) и errors.Is(err, sql.ErrNoRows)
+var ErrNotFound = fmt.Errorf("record not found: %w", sql.ErrNoRows)
+
+// ErrNoSchemaChanges возвращается GenerateFromModels, если схема таблиц уже
+// соответствует моделям
+var ErrNoSchemaChanges = errors.New("no schema changes")
+
+// timeoutError оборачивает ошибку драйвера, вызванную таймаутом
+type timeoutError struct {
+	err error
+}
+
+func (e *timeoutError) Error() string {
+	return e.err.Error()
+}
+
+func (e *timeoutError) Unwrap() error {
+	return e.err
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrTimeout)
+func (e *timeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// classifyError помечает ошибки драйвера, вызванные таймаутами
+func classifyError(err error) error {
+	if err == nil || !isTimeout(err) {
+		return err
+	}
+	return &timeoutError{err: err}
+}
+
+// isTimeout проверяет, вызвана ли ошибка таймаутом
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// Код 159 (TIMEOUT_EXCEEDED) возвращается сервером при превышении max_execution_time
+	msg := err.Error()
+	return strings.Contains(msg, "TIMEOUT_EXCEEDED") || strings.Contains(msg, "code: 159")
+}
+
+// isConnectionError проверяет, вызвана ли ошибка потерей соединения с сервером.
+// Таймауты не считаются ошибками соединения
+func isConnectionError(err error) bool {
+	if err == nil || isTimeout(err) {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	// Драйвер возвращает часть ошибок соединения только текстом
+	msg := err.Error()
+	return strings.Contains(msg, "connection is already closed") || strings.Contains(msg, "broken pipe")
+}
+
+// StatementError описывает ошибку одного запроса из ExecMulti
+type StatementError struct {
+	Index int
+	SQL   string
+	Err   error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d (%s): %v", e.Index, truncateText(e.SQL, maxLoggedSQLLength), e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError объединяет ошибки, накопленные ExecMultiContinueOnError
+type MultiError []error
+
+func (e MultiError) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Unwrap позволяет проверять отдельные ошибки через errors.Is и errors.As
+func (e MultiError) Unwrap() []error {
+	return e
+}
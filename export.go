@@ -0,0 +1,267 @@
+package chorm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы вывода ClickHouse для Export
+const (
+	FormatCSV          = "CSV"
+	FormatCSVWithNames = "CSVWithNames"
+	FormatTSVWithNames = "TSVWithNames"
+	FormatJSONEachRow  = "JSONEachRow"
+	FormatParquet      = "Parquet"
+)
+
+// maxHTTPErrorBodySize ограничивает размер текста ошибки из ответа HTTP интерфейса
+const maxHTTPErrorBodySize = 64 << 10
+
+// Export выполняет запрос с суффиксом FORMAT format через HTTP интерфейс
+// ClickHouse и потоково копирует ответ сервера в w без разбора строк на
+// стороне Go. Аргументы подставляются в запрос как литералы. Требуется
+// протокол HTTP (CapabilityFormatStreaming)
+func (q *Query) Export(ctx context.Context, w io.Writer, format string) error {
+	if err := q.db.requireCapability(CapabilityFormatStreaming); err != nil {
+		return err
+	}
+	if err := q.validate(); err != nil {
+		return err
+	}
+	if format == "" || strings.ContainsAny(format, " \t\n;") {
+		return fmt.Errorf("invalid export format %q", format)
+	}
+
+	sql, err := interpolateArgs(q.buildQuery(), driverArgs(q.queryArgs()))
+	if err != nil {
+		return fmt.Errorf("failed to build export query: %w", err)
+	}
+	sql += " FORMAT " + format
+
+	// Журнал и хуки получают запрос с замаскированными SensitiveValue,
+	// на сервер отправляется запрос с исходными значениями
+	redacted := q.String() + " FORMAT " + format
+	q.db.debugf("Export SQL: %s", redacted)
+
+	ctx, event := q.db.beforeQuery(q.context(ctx), redacted, nil)
+	n, _, err := q.db.httpQuery(ctx, sql, nil, w)
+	if err := q.db.finishQuery(ctx, event, 0, err); err != nil {
+		return fmt.Errorf("failed to export query (%d bytes written): %w", n, err)
+	}
+
+	return nil
+}
+
+// httpSummary - сводка выполнения из заголовка X-ClickHouse-Summary
+type httpSummary struct {
+	WrittenRows int64 `json:"written_rows,string"`
+	ReadRows    int64 `json:"read_rows,string"`
+}
+
+// httpQuery отправляет запрос в HTTP интерфейс ClickHouse и копирует тело
+// ответа в w. Если задан body, запрос передается в параметре query, а body
+// потоково отправляется как данные запроса (INSERT ... FORMAT)
+func (db *DB) httpQuery(ctx context.Context, query string, body io.Reader, w io.Writer) (int64, httpSummary, error) {
+	var summary httpSummary
+
+	client, err := db.httpClient()
+	if err != nil {
+		return 0, summary, err
+	}
+
+	params := url.Values{}
+	if body == nil {
+		body = strings.NewReader(query)
+	} else {
+		params.Set("query", query)
+	}
+	if quotaKey, ok := ctx.Value(quotaKeyKey{}).(string); ok {
+		params.Set("quota_key", quotaKey)
+	}
+	endpoint := db.httpURL(params)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return 0, summary, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	if db.config.Username != "" {
+		req.Header.Set("X-ClickHouse-User", db.config.Username)
+	}
+	if db.config.Password != "" {
+		req.Header.Set("X-ClickHouse-Key", db.config.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, summary, redactError(err, db.config.Password)
+	}
+	defer resp.Body.Close()
+
+	if header := resp.Header.Get("X-ClickHouse-Summary"); header != "" {
+		json.Unmarshal([]byte(header), &summary)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxHTTPErrorBodySize))
+		return 0, summary, fmt.Errorf("clickhouse HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if w == nil {
+		w = io.Discard
+	}
+	n, err := io.Copy(w, resp.Body)
+	return n, summary, err
+}
+
+// httpURL строит адрес HTTP интерфейса с базой данных, настройками соединения
+// и дополнительными параметрами params
+func (db *DB) httpURL(params url.Values) string {
+	scheme := "http"
+	if db.config.TLS {
+		scheme = "https"
+	}
+
+	if params == nil {
+		params = url.Values{}
+	}
+	if db.config.Database != "" {
+		params.Set("database", db.config.Database)
+	}
+	if db.config.MaxExecutionTime > 0 {
+		params.Set("max_execution_time", strconv.FormatInt(durationSeconds(db.config.MaxExecutionTime), 10))
+	}
+	for k, v := range db.config.Settings {
+		params.Set(k, dsnSettingValue(v))
+	}
+
+	u := url.URL{Scheme: scheme, Host: fmt.Sprintf("%s:%d", db.config.Host, db.config.Port), Path: "/", RawQuery: params.Encode()}
+	return u.String()
+}
+
+// httpClient возвращает HTTP клиент с сертификатами TLS из конфигурации
+func (db *DB) httpClient() (*http.Client, error) {
+	if !db.config.TLS || (db.config.TLSCAFile == "" && db.config.TLSCertFile == "") {
+		return http.DefaultClient, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if db.config.TLSCAFile != "" {
+		ca, err := os.ReadFile(db.config.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to parse TLS CA file %s", db.config.TLSCAFile)
+		}
+	}
+	if db.config.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(db.config.TLSCertFile, db.config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// interpolateArgs подставляет аргументы вместо ? вне строковых литералов и
+// идентификаторов
+func interpolateArgs(sql string, args []interface{}) (string, error) {
+	var b strings.Builder
+	b.Grow(len(sql) + 16*len(args))
+
+	n := 0
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(sql) {
+				b.WriteByte(c)
+				i++
+				c = sql[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			if n >= len(args) {
+				return "", fmt.Errorf("query has more placeholders than %d arguments", len(args))
+			}
+			b.WriteString(formatLiteral(args[n]))
+			n++
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	if n != len(args) {
+		return "", fmt.Errorf("query has %d placeholders, got %d arguments", n, len(args))
+	}
+	return b.String(), nil
+}
+
+// formatLiteral форматирует значение как литерал ClickHouse
+func formatLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case SensitiveValue:
+		return quoteString(redactedValue)
+	case string:
+		return quoteString(v)
+	case []byte:
+		return quoteString(string(v))
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case time.Time:
+		return fmt.Sprintf("parseDateTime64BestEffort(%s, 9)", quoteString(v.Format(time.RFC3339Nano)))
+	case driver.Valuer:
+		inner, err := v.Value()
+		if err != nil {
+			return "NULL"
+		}
+		return formatLiteral(inner)
+	case fmt.Stringer:
+		return quoteString(v.String())
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "NULL"
+		}
+		return formatLiteral(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = formatLiteral(rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.String:
+		return quoteString(rv.String())
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
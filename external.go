@@ -0,0 +1,200 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MySQLTableBuilder представляет построитель таблицы на движке MySQL
+type MySQLTableBuilder struct {
+	name     string
+	columns  []string
+	host     string
+	port     int
+	database string
+	table    string
+	user     string
+	password string
+}
+
+// NewMySQLTable создает построитель таблицы MySQL
+func NewMySQLTable(name string) *MySQLTableBuilder {
+	return &MySQLTableBuilder{
+		name:    name,
+		columns: make([]string, 0),
+		port:    3306,
+	}
+}
+
+// Column добавляет колонку
+func (mb *MySQLTableBuilder) Column(name, dataType string) *MySQLTableBuilder {
+	mb.columns = append(mb.columns, fmt.Sprintf("%s %s", name, dataType))
+	return mb
+}
+
+// Host устанавливает хост MySQL
+func (mb *MySQLTableBuilder) Host(host string) *MySQLTableBuilder {
+	mb.host = host
+	return mb
+}
+
+// Port устанавливает порт MySQL (по умолчанию 3306)
+func (mb *MySQLTableBuilder) Port(port int) *MySQLTableBuilder {
+	mb.port = port
+	return mb
+}
+
+// Database устанавливает базу данных MySQL
+func (mb *MySQLTableBuilder) Database(database string) *MySQLTableBuilder {
+	mb.database = database
+	return mb
+}
+
+// Table устанавливает удаленную таблицу MySQL
+func (mb *MySQLTableBuilder) Table(table string) *MySQLTableBuilder {
+	mb.table = table
+	return mb
+}
+
+// User устанавливает пользователя MySQL
+func (mb *MySQLTableBuilder) User(user string) *MySQLTableBuilder {
+	mb.user = user
+	return mb
+}
+
+// Password устанавливает пароль MySQL
+func (mb *MySQLTableBuilder) Password(password string) *MySQLTableBuilder {
+	mb.password = password
+	return mb
+}
+
+// BuildCreateSQL строит SQL для создания таблицы MySQL
+func (mb *MySQLTableBuilder) BuildCreateSQL() string {
+	return buildExternalTableSQL(mb.name, mb.columns, "MySQL",
+		fmt.Sprintf("%s:%d", mb.host, mb.port), mb.database, mb.table, mb.user, mb.password)
+}
+
+// Create создает таблицу MySQL
+func (mb *MySQLTableBuilder) Create(ctx context.Context, db *DB) error {
+	if err := validateExternalTable("MySQL", mb.name, mb.columns, mb.host, mb.database, mb.table); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(ctx, mb.BuildCreateSQL())
+	return err
+}
+
+// PostgreSQLTableBuilder представляет построитель таблицы на движке PostgreSQL
+type PostgreSQLTableBuilder struct {
+	name     string
+	columns  []string
+	host     string
+	port     int
+	database string
+	table    string
+	user     string
+	password string
+	schema   string
+}
+
+// NewPostgreSQLTable создает построитель таблицы PostgreSQL
+func NewPostgreSQLTable(name string) *PostgreSQLTableBuilder {
+	return &PostgreSQLTableBuilder{
+		name:    name,
+		columns: make([]string, 0),
+		port:    5432,
+	}
+}
+
+// Column добавляет колонку
+func (pb *PostgreSQLTableBuilder) Column(name, dataType string) *PostgreSQLTableBuilder {
+	pb.columns = append(pb.columns, fmt.Sprintf("%s %s", name, dataType))
+	return pb
+}
+
+// Host устанавливает хост PostgreSQL
+func (pb *PostgreSQLTableBuilder) Host(host string) *PostgreSQLTableBuilder {
+	pb.host = host
+	return pb
+}
+
+// Port устанавливает порт PostgreSQL (по умолчанию 5432)
+func (pb *PostgreSQLTableBuilder) Port(port int) *PostgreSQLTableBuilder {
+	pb.port = port
+	return pb
+}
+
+// Database устанавливает базу данных PostgreSQL
+func (pb *PostgreSQLTableBuilder) Database(database string) *PostgreSQLTableBuilder {
+	pb.database = database
+	return pb
+}
+
+// Table устанавливает удаленную таблицу PostgreSQL
+func (pb *PostgreSQLTableBuilder) Table(table string) *PostgreSQLTableBuilder {
+	pb.table = table
+	return pb
+}
+
+// User устанавливает пользователя PostgreSQL
+func (pb *PostgreSQLTableBuilder) User(user string) *PostgreSQLTableBuilder {
+	pb.user = user
+	return pb
+}
+
+// Password устанавливает пароль PostgreSQL
+func (pb *PostgreSQLTableBuilder) Password(password string) *PostgreSQLTableBuilder {
+	pb.password = password
+	return pb
+}
+
+// Schema устанавливает схему PostgreSQL
+func (pb *PostgreSQLTableBuilder) Schema(schema string) *PostgreSQLTableBuilder {
+	pb.schema = schema
+	return pb
+}
+
+// BuildCreateSQL строит SQL для создания таблицы PostgreSQL
+func (pb *PostgreSQLTableBuilder) BuildCreateSQL() string {
+	params := []string{fmt.Sprintf("%s:%d", pb.host, pb.port), pb.database, pb.table, pb.user, pb.password}
+	if pb.schema != "" {
+		params = append(params, pb.schema)
+	}
+	return buildExternalTableSQL(pb.name, pb.columns, "PostgreSQL", params...)
+}
+
+// Create создает таблицу PostgreSQL
+func (pb *PostgreSQLTableBuilder) Create(ctx context.Context, db *DB) error {
+	if err := validateExternalTable("PostgreSQL", pb.name, pb.columns, pb.host, pb.database, pb.table); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(ctx, pb.BuildCreateSQL())
+	return err
+}
+
+// validateExternalTable проверяет обязательные параметры внешней таблицы
+func validateExternalTable(engine, name string, columns []string, host, database, table string) error {
+	if name == "" {
+		return fmt.Errorf("%s table name is required", engine)
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("%s table %s: at least one column is required", engine, name)
+	}
+	if host == "" || database == "" || table == "" {
+		return fmt.Errorf("%s table %s: host, database and table are required", engine, name)
+	}
+	return nil
+}
+
+// buildExternalTableSQL строит CREATE TABLE для движков внешних баз данных
+func buildExternalTableSQL(name string, columns []string, engine string, params ...string) string {
+	quoted := make([]string, len(params))
+	for i, p := range params {
+		quoted[i] = quoteString(p)
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n) ENGINE = %s(%s)",
+		name, strings.Join(columns, ",\n  "), engine, strings.Join(quoted, ", "))
+}
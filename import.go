@@ -0,0 +1,122 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ImportStream потоково отправляет данные из r в INSERT INTO table FORMAT
+// format через HTTP интерфейс ClickHouse без разбора строк на стороне Go.
+// Ошибка чтения r прерывает запрос; строки, разобранные сервером до ошибки
+// разбора данных, могут быть уже вставлены. Требуется протокол HTTP
+// (CapabilityFormatStreaming)
+func (db *DB) ImportStream(ctx context.Context, table string, format string, r io.Reader) error {
+	if err := db.requireCapability(CapabilityFormatStreaming); err != nil {
+		return err
+	}
+	if format == "" || strings.ContainsAny(format, " \t\n;") {
+		return fmt.Errorf("invalid import format %q", format)
+	}
+
+	sql := fmt.Sprintf("INSERT INTO %s FORMAT %s", db.quoteTable(table), format)
+
+	db.debugf("Import SQL: %s", sql)
+
+	body := &importReader{r: r}
+	ctx, event := db.beforeQuery(ctx, sql, nil)
+	_, summary, err := db.httpQuery(ctx, event.SQL, body, nil)
+	if n, readErr := body.result(); readErr != nil {
+		// Ошибка источника данных важнее ответа сервера на оборванный запрос
+		err = fmt.Errorf("failed to read import stream after %d bytes: %w", n, readErr)
+	}
+	if err := db.finishQuery(ctx, event, summary.WrittenRows, err); err != nil {
+		return fmt.Errorf("failed to import into %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// importReader считает переданные байты и запоминает ошибку источника.
+// Read вызывается из горутины HTTP транспорта
+type importReader struct {
+	r   io.Reader
+	mu  sync.Mutex
+	n   int64
+	err error
+}
+
+func (r *importReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.n += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// result возвращает число прочитанных байт и ошибку источника
+func (r *importReader) result() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.n, r.err
+}
+
+// InsertFromS3 вставляет в destTable данные из S3 запросом INSERT INTO
+// destTable SELECT * FROM s3(url, [key, secret,] format[, schema]).
+// credentials - пустой список для публичных объектов либо ключ доступа и
+// секрет, которые скрываются в журналах. Пустая schema не передается, и
+// структура определяется сервером по данным:
+//
+//	result, err := db.InsertFromS3(ctx, "events",
+//		"https://bucket.s3.amazonaws.com/events/*.parquet", "Parquet", "", key, secret)
+func (db *DB) InsertFromS3(ctx context.Context, destTable, s3URL, format, schema string, credentials ...string) (Result, error) {
+	if len(credentials) != 0 && len(credentials) != 2 {
+		return Result{}, fmt.Errorf("s3 credentials must be an access key and a secret, got %d values", len(credentials))
+	}
+
+	args := []interface{}{s3URL}
+	for _, credential := range credentials {
+		args = append(args, Sensitive(credential))
+	}
+	return db.insertFromTableFunction(ctx, destTable, "s3", format, schema, args)
+}
+
+// InsertFromFile вставляет в destTable данные файла filePath запросом
+// INSERT INTO destTable SELECT * FROM file(path, format). Файл читается
+// сервером ClickHouse относительно его user_files_path, а не клиентом; для
+// отправки локальных данных используйте ImportStream
+func (db *DB) InsertFromFile(ctx context.Context, destTable, filePath, format string) (Result, error) {
+	return db.insertFromTableFunction(ctx, destTable, "file", format, "", []interface{}{filePath})
+}
+
+// insertFromTableFunction выполняет INSERT SELECT из табличной функции fn.
+// Аргументы source, формат и схема передаются плейсхолдерами
+func (db *DB) insertFromTableFunction(ctx context.Context, destTable, fn, format, schema string, source []interface{}) (Result, error) {
+	if format == "" {
+		return Result{}, fmt.Errorf("%s format is required", fn)
+	}
+
+	args := append(source, format)
+	if schema != "" {
+		args = append(args, schema)
+	}
+	// Имя с базой разделяется, без базы уточняется базой UseDatabase
+	database, table := splitTable(destTable)
+	if database == "" {
+		database = db.database
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	sql := fmt.Sprintf("INSERT INTO %s SELECT * FROM %s(%s)", quoteTable(database, table), fn, placeholders)
+
+	result, err := db.Exec(ctx, sql, args...)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to insert into %s from %s: %w", destTable, fn, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,96 @@
+// Package chcontainer запускает ClickHouse в Docker через testcontainers для
+// интеграционных тестов. Пакет вынесен в отдельный модуль, чтобы основной
+// модуль chorm не зависел от testcontainers и Docker клиента
+package chcontainer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Image - образ сервера по умолчанию
+const Image = "clickhouse/clickhouse-server:24.3-alpine"
+
+// Учетные данные пользователя, создаваемого в контейнере. Без пароля
+// образ разрешает пользователю default только локальные подключения
+const (
+	Username = "chorm"
+	Password = "chorm"
+)
+
+// Container - запущенный сервер ClickHouse
+type Container struct {
+	container  testcontainers.Container
+	Host       string
+	NativePort int
+	HTTPPort   int
+}
+
+// Start запускает контейнер image (Image, если пусто) и ждет готовности
+// HTTP интерфейса
+func Start(ctx context.Context, image string) (*Container, error) {
+	if image == "" {
+		image = Image
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        image,
+			ExposedPorts: []string{"9000/tcp", "8123/tcp"},
+			Env: map[string]string{
+				"CLICKHOUSE_USER":                      Username,
+				"CLICKHOUSE_PASSWORD":                  Password,
+				"CLICKHOUSE_DEFAULT_ACCESS_MANAGEMENT": "1",
+			},
+			WaitingFor: wait.ForHTTP("/ping").WithPort("8123/tcp"),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start clickhouse container: %w", err)
+	}
+
+	c := &Container{container: container}
+	if c.Host, err = container.Host(ctx); err != nil {
+		container.Terminate(ctx)
+		return nil, fmt.Errorf("failed to get container host: %w", err)
+	}
+	native, err := container.MappedPort(ctx, "9000/tcp")
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, fmt.Errorf("failed to get native port: %w", err)
+	}
+	http, err := container.MappedPort(ctx, "8123/tcp")
+	if err != nil {
+		container.Terminate(ctx)
+		return nil, fmt.Errorf("failed to get HTTP port: %w", err)
+	}
+	c.NativePort, c.HTTPPort = native.Int(), http.Int()
+
+	return c, nil
+}
+
+// Exec выполняет запрос query через clickhouse-client внутри контейнера
+func (c *Container) Exec(ctx context.Context, query string) error {
+	code, output, err := c.container.Exec(ctx, []string{
+		"clickhouse-client", "--user", Username, "--password", Password, "--query", query,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute query in container: %w", err)
+	}
+	if code != 0 {
+		message, _ := io.ReadAll(output)
+		return fmt.Errorf("clickhouse-client exited with code %d: %s", code, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// Terminate останавливает и удаляет контейнер
+func (c *Container) Terminate(ctx context.Context) error {
+	return c.container.Terminate(ctx)
+}
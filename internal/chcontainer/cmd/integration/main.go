@@ -0,0 +1,62 @@
+// Команда integration запускает ClickHouse в контейнере, создает базу test и
+// выполняет go test с переданными аргументами, направляя интеграционные
+// тесты chorm в контейнер через переменные CHORM_TEST_*:
+//
+//	cd internal/chcontainer && go run ./cmd/integration -dir ../.. -v ./...
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/AlanForester/chorm/internal/chcontainer"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run возвращает код завершения go test либо 1 при ошибке запуска контейнера
+func run() int {
+	dir := flag.String("dir", ".", "directory to run go test in")
+	image := flag.String("image", "", "ClickHouse image, "+chcontainer.Image+" if empty")
+	flag.Parse()
+
+	ctx := context.Background()
+	container, err := chcontainer.Start(ctx, *image)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer container.Terminate(ctx)
+
+	if err := container.Exec(ctx, "CREATE DATABASE IF NOT EXISTS test"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	cmd := exec.Command("go", append([]string{"test"}, flag.Args()...)...)
+	cmd.Dir = *dir
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(),
+		"CHORM_TEST_HOST="+container.Host,
+		"CHORM_TEST_PORT="+strconv.Itoa(container.NativePort),
+		"CHORM_TEST_HTTP_PORT="+strconv.Itoa(container.HTTPPort),
+		"CHORM_TEST_USERNAME="+chcontainer.Username,
+		"CHORM_TEST_PASSWORD="+chcontainer.Password,
+	)
+
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
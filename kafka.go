@@ -0,0 +1,159 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KafkaTableBuilder представляет построитель таблицы на движке Kafka
+type KafkaTableBuilder struct {
+	name              string
+	columns           []string
+	brokers           []string
+	topic             string
+	group             string
+	format            string
+	numConsumers      int
+	schemaRegistryURL string
+	settings          map[string]string
+}
+
+// NewKafkaTable создает построитель таблицы Kafka
+func NewKafkaTable(name string) *KafkaTableBuilder {
+	return &KafkaTableBuilder{
+		name:     name,
+		columns:  make([]string, 0),
+		settings: make(map[string]string),
+	}
+}
+
+// Column добавляет колонку
+func (kb *KafkaTableBuilder) Column(name, dataType string) *KafkaTableBuilder {
+	kb.columns = append(kb.columns, fmt.Sprintf("%s %s", name, dataType))
+	return kb
+}
+
+// Brokers устанавливает список брокеров
+func (kb *KafkaTableBuilder) Brokers(brokers ...string) *KafkaTableBuilder {
+	kb.brokers = brokers
+	return kb
+}
+
+// Topic устанавливает топик
+func (kb *KafkaTableBuilder) Topic(t string) *KafkaTableBuilder {
+	kb.topic = t
+	return kb
+}
+
+// ConsumerGroup устанавливает группу потребителей
+func (kb *KafkaTableBuilder) ConsumerGroup(g string) *KafkaTableBuilder {
+	kb.group = g
+	return kb
+}
+
+// Format устанавливает формат сообщений (JSONEachRow, Avro, ...)
+func (kb *KafkaTableBuilder) Format(f string) *KafkaTableBuilder {
+	kb.format = f
+	return kb
+}
+
+// NumConsumers устанавливает количество потребителей
+func (kb *KafkaTableBuilder) NumConsumers(n int) *KafkaTableBuilder {
+	kb.numConsumers = n
+	return kb
+}
+
+// SchemaRegistryURL устанавливает адрес Schema Registry (для формата AvroConfluent)
+func (kb *KafkaTableBuilder) SchemaRegistryURL(u string) *KafkaTableBuilder {
+	kb.schemaRegistryURL = u
+	return kb
+}
+
+// Setting добавляет произвольную настройку движка
+func (kb *KafkaTableBuilder) Setting(key, value string) *KafkaTableBuilder {
+	kb.settings[key] = value
+	return kb
+}
+
+// validate проверяет обязательные параметры движка
+func (kb *KafkaTableBuilder) validate() error {
+	if kb.name == "" {
+		return fmt.Errorf("kafka table name is required")
+	}
+	if len(kb.columns) == 0 {
+		return fmt.Errorf("kafka table %s: at least one column is required", kb.name)
+	}
+	if len(kb.brokers) == 0 {
+		return fmt.Errorf("kafka table %s: brokers are required", kb.name)
+	}
+	if kb.topic == "" || kb.group == "" || kb.format == "" {
+		return fmt.Errorf("kafka table %s: topic, consumer group and format are required", kb.name)
+	}
+	return nil
+}
+
+// BuildCreateSQL строит SQL для создания таблицы Kafka
+func (kb *KafkaTableBuilder) BuildCreateSQL() string {
+	var parts []string
+
+	parts = append(parts, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (", kb.name))
+	parts = append(parts, "  "+strings.Join(kb.columns, ",\n  "))
+	parts = append(parts, ")")
+
+	parts = append(parts, fmt.Sprintf("ENGINE = Kafka(%s, %s, %s, %s)",
+		quoteString(strings.Join(kb.brokers, ",")),
+		quoteString(kb.topic),
+		quoteString(kb.group),
+		quoteString(kb.format)))
+
+	// SETTINGS
+	var settings []string
+	if kb.numConsumers > 0 {
+		settings = append(settings, fmt.Sprintf("kafka_num_consumers = %d", kb.numConsumers))
+	}
+	if kb.schemaRegistryURL != "" {
+		settings = append(settings, fmt.Sprintf("format_avro_schema_registry_url = %s", quoteString(kb.schemaRegistryURL)))
+	}
+
+	keys := make([]string, 0, len(kb.settings))
+	for k := range kb.settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		settings = append(settings, fmt.Sprintf("%s = %s", k, kb.settings[k]))
+	}
+
+	if len(settings) > 0 {
+		parts = append(parts, fmt.Sprintf("SETTINGS %s", strings.Join(settings, ", ")))
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// Create создает таблицу Kafka
+func (kb *KafkaTableBuilder) Create(ctx context.Context, db *DB) error {
+	if err := kb.validate(); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(ctx, kb.BuildCreateSQL())
+	return err
+}
+
+// KafkaMaterializedView создает материализованное представление <sourceTable>_mv,
+// которое переносит сообщения из таблицы Kafka в целевую таблицу.
+// Если selectSQL пустой, используется SELECT * FROM sourceTable
+func KafkaMaterializedView(ctx context.Context, db *DB, sourceTable, targetTable, selectSQL string) error {
+	if selectSQL == "" {
+		selectSQL = fmt.Sprintf("SELECT * FROM %s", sourceTable)
+	}
+
+	sql := fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s_mv TO %s AS %s",
+		sourceTable, targetTable, selectSQL)
+
+	_, err := db.Exec(ctx, sql)
+	return err
+}
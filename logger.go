@@ -0,0 +1,159 @@
+package chorm
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// Logger принимает сообщения chorm: Debugf - SQL и аргументы запросов в
+// режиме Debug, Errorf - ошибки, которые нельзя вернуть вызывающему коду.
+// Если Logger реализует Warnf(format string, args ...interface{}), через него
+// журналируются медленные запросы, иначе через Errorf. Если Logger реализует
+// Infof(format string, args ...interface{}), через него журналируется план
+// пробного запуска миграций, иначе через Debugf
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// warnLogger - необязательное расширение Logger для предупреждений
+type warnLogger interface {
+	Warnf(format string, args ...interface{})
+}
+
+// infoLogger - необязательное расширение Logger для информационных сообщений
+type infoLogger interface {
+	Infof(format string, args ...interface{})
+}
+
+// NopLogger отбрасывает все сообщения
+type NopLogger struct{}
+
+func (NopLogger) Debugf(format string, args ...interface{}) {}
+func (NopLogger) Errorf(format string, args ...interface{}) {}
+
+// StdLogger журналирует через стандартный log.Logger
+type StdLogger struct {
+	Logger *log.Logger
+}
+
+// NewStdLogger создает StdLogger поверх l; nil означает вывод в stdout без
+// префиксов и времени
+func NewStdLogger(l *log.Logger) *StdLogger {
+	if l == nil {
+		l = log.New(os.Stdout, "", 0)
+	}
+	return &StdLogger{Logger: l}
+}
+
+func (l *StdLogger) Debugf(format string, args ...interface{}) {
+	l.Logger.Output(2, fmt.Sprintf(format, args...))
+}
+
+func (l *StdLogger) Infof(format string, args ...interface{}) {
+	l.Logger.Output(2, fmt.Sprintf(format, args...))
+}
+
+func (l *StdLogger) Warnf(format string, args ...interface{}) {
+	l.Logger.Output(2, "WARN "+fmt.Sprintf(format, args...))
+}
+
+func (l *StdLogger) Errorf(format string, args ...interface{}) {
+	l.Logger.Output(2, "ERROR "+fmt.Sprintf(format, args...))
+}
+
+// debugLogFlags - формат времени в строках журнала по умолчанию
+const debugLogFlags = log.LstdFlags | log.Lmicroseconds
+
+// debugWriteMu упорядочивает запись строк журнала по умолчанию: журнал
+// создается при каждом сообщении, а DebugWriter может не допускать
+// одновременной записи (например, bytes.Buffer)
+var debugWriteMu sync.Mutex
+
+// lockedWriter записывает в w под debugWriteMu
+type lockedWriter struct {
+	w io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	debugWriteMu.Lock()
+	defer debugWriteMu.Unlock()
+	return l.w.Write(p)
+}
+
+// defaultLogger возвращает StdLogger, выводящий строки с временем в
+// Config.DebugWriter (по умолчанию stdout)
+func (db *DB) defaultLogger() Logger {
+	w := db.config.DebugWriter
+	if w == nil {
+		w = os.Stdout
+	}
+	return NewStdLogger(log.New(lockedWriter{w}, "", debugLogFlags))
+}
+
+// configuredLogger возвращает журнал из конфигурации: Config.Slog имеет
+// приоритет над Config.Logger. Если ни один не задан, возвращает nil
+func (db *DB) configuredLogger() Logger {
+	if db.config.Slog != nil {
+		return slogLogger{db.config.Slog}
+	}
+	return db.config.Logger
+}
+
+// logger возвращает журнал из конфигурации, а если он не задан - журнал по
+// умолчанию в режиме Debug и NopLogger в остальных случаях
+func (db *DB) logger() Logger {
+	if logger := db.configuredLogger(); logger != nil {
+		return logger
+	}
+	if db.config.Debug {
+		return db.defaultLogger()
+	}
+	return NopLogger{}
+}
+
+// debugf журналирует отладочное сообщение в режиме Debug
+func (db *DB) debugf(format string, args ...interface{}) {
+	if db.config.Debug {
+		db.logger().Debugf(format, args...)
+	}
+}
+
+// errorf журналирует ошибку, которую нельзя вернуть вызывающему коду
+func (db *DB) errorf(format string, args ...interface{}) {
+	db.logger().Errorf(format, args...)
+}
+
+// warnf журналирует предупреждение. Без Config.Logger и Config.Slog
+// предупреждения выводятся в журнал по умолчанию независимо от режима Debug
+func (db *DB) warnf(format string, args ...interface{}) {
+	logger := db.configuredLogger()
+	if logger == nil {
+		logger = db.defaultLogger()
+	}
+	if w, ok := logger.(warnLogger); ok {
+		w.Warnf(format, args...)
+		return
+	}
+	logger.Errorf(format, args...)
+}
+
+// infof журналирует информационное сообщение (например, план пробного
+// запуска миграций). Как и предупреждения, без Config.Logger и Config.Slog
+// оно выводится в журнал по умолчанию независимо от режима Debug. Если
+// Logger не реализует Infof(format string, args ...interface{}), сообщение
+// передается в Debugf
+func (db *DB) infof(format string, args ...interface{}) {
+	logger := db.configuredLogger()
+	if logger == nil {
+		logger = db.defaultLogger()
+	}
+	if l, ok := logger.(infoLogger); ok {
+		l.Infof(format, args...)
+		return
+	}
+	logger.Debugf(format, args...)
+}
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Mapper представляет маппер для работы со структурами
@@ -41,12 +42,17 @@ func (m *Mapper) ParseStruct(model interface{}) (*TableInfo, error) {
 	info := &TableInfo{
 		Name:    tableName,
 		Fields:  make([]FieldInfo, 0),
-		Engine:  string(EngineMergeTree),
 		Options: make(map[string]string),
 	}
 
 	for i := 0; i < typ.NumField(); i++ {
 		field := typ.Field(i)
+
+		// Движок таблицы задается тегом ch_engine любого поля структуры
+		if engine := field.Tag.Get("ch_engine"); engine != "" {
+			info.Engine = engine
+		}
+
 		fieldInfo, err := m.parseField(field)
 		if err != nil {
 			return nil, fmt.Errorf("error parsing field %s: %w", field.Name, err)
@@ -66,8 +72,9 @@ func (m *Mapper) ParseStruct(model interface{}) (*TableInfo, error) {
 // parseField парсит отдельное поле структуры
 func (m *Mapper) parseField(field reflect.StructField) (FieldInfo, error) {
 	info := FieldInfo{
-		Name: field.Name,
-		Type: string(TypeString), // По умолчанию
+		Name:      field.Name,
+		FieldName: field.Name,
+		Type:      string(TypeString), // По умолчанию
 	}
 
 	// Парсим тег ch
@@ -88,6 +95,10 @@ func (m *Mapper) parseField(field reflect.StructField) (FieldInfo, error) {
 		info.IsPK = true
 	}
 
+	if err := parseUnixTime(field, &info); err != nil {
+		return info, err
+	}
+
 	if field.Tag.Get("ch_auto") == "true" {
 		info.IsAuto = true
 	}
@@ -96,9 +107,86 @@ func (m *Mapper) parseField(field reflect.StructField) (FieldInfo, error) {
 		info.Nullable = true
 	}
 
-	// Парсим движок таблицы
-	if engine := field.Tag.Get("ch_engine"); engine != "" {
-		// Это должно быть на уровне структуры, но для простоты обрабатываем здесь
+	if expr := field.Tag.Get("ch_materialized"); expr != "" {
+		info.Materialized = expr
+	}
+
+	if expr := field.Tag.Get("ch_alias"); expr != "" {
+		info.Alias = expr
+	}
+
+	if field.Tag.Get("ch_sensitive") == "true" {
+		info.Sensitive = true
+	}
+
+	if field.Tag.Get("ch_required") == "true" {
+		info.Required = true
+	}
+
+	if max := field.Tag.Get("ch_max"); max != "" {
+		typ := field.Type
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.String {
+			return info, fmt.Errorf("ch_max is supported only for string fields, got %s", field.Type)
+		}
+		n, err := strconv.Atoi(max)
+		if err != nil || n < 0 {
+			return info, fmt.Errorf("invalid ch_max %q: must be a non-negative integer", max)
+		}
+		info.MaxLength = n
+	}
+
+	if field.Tag.Get("ch_version") == "true" {
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return info, fmt.Errorf("ch_version is supported only for integer fields, got %s", field.Type)
+		}
+		info.IsVersion = true
+	}
+
+	if field.Tag.Get("ch_soft_delete") == "true" {
+		if !isSoftDeleteType(field.Type) {
+			return info, fmt.Errorf("ch_soft_delete is supported only for time.Time and *time.Time fields, got %s", field.Type)
+		}
+		info.SoftDelete = true
+		if field.Type.Kind() == reflect.Ptr {
+			info.Nullable = true
+			if field.Tag.Get("ch_type") == "" {
+				info.Type = "Nullable(DateTime)"
+			}
+		}
+	}
+
+	for _, tag := range []string{"ch_created_at", "ch_updated_at"} {
+		if field.Tag.Get(tag) != "true" {
+			continue
+		}
+		if field.Type != reflect.TypeOf(time.Time{}) {
+			return info, fmt.Errorf("%s is supported only for time.Time fields, got %s", tag, field.Type)
+		}
+		if tag == "ch_created_at" {
+			info.CreatedAt = true
+		} else {
+			info.UpdatedAt = true
+		}
+	}
+
+	for _, tag := range []string{"ch_created_by", "ch_updated_by"} {
+		if field.Tag.Get(tag) != "true" {
+			continue
+		}
+		if field.Type.Kind() != reflect.String {
+			return info, fmt.Errorf("%s is supported only for string fields, got %s", tag, field.Type)
+		}
+		if tag == "ch_created_by" {
+			info.CreatedBy = true
+		} else {
+			info.UpdatedBy = true
+		}
 	}
 
 	return info, nil
@@ -131,6 +219,9 @@ func (m *Mapper) goTypeToClickHouseType(typ reflect.Type) string {
 		if typ.String() == "time.Time" {
 			return string(TypeDateTime)
 		}
+		if isTupleStruct(typ) {
+			return m.tupleType(typ)
+		}
 		return string(TypeString) // По умолчанию
 	default:
 		return string(TypeString)
@@ -153,6 +244,24 @@ func (m *Mapper) getTableName(model interface{}, typ reflect.Type) string {
 	return strings.ToLower(typ.Name())
 }
 
+// insertable сообщает, передается ли поле в INSERT.
+// Вычисляемые колонки заполняются сервером, а колонка ch_soft_delete
+// получает значение по умолчанию (NULL или начало эпохи)
+func (f FieldInfo) insertable() bool {
+	return f.Materialized == "" && f.Alias == "" && !f.SoftDelete
+}
+
+// fieldByColumn находит поле структуры по имени колонки (тег ch) или имени поля
+func fieldByColumn(val reflect.Value, column string) reflect.Value {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).Tag.Get("ch") == column {
+			return val.Field(i)
+		}
+	}
+	return val.FieldByName(column)
+}
+
 // GetFieldValue получает значение поля из структуры
 func (m *Mapper) GetFieldValue(model interface{}, fieldName string) (interface{}, error) {
 	val := reflect.ValueOf(model)
@@ -286,7 +395,7 @@ func (m *Mapper) GetPrimaryKey(model interface{}) (string, interface{}, error) {
 
 	for _, field := range info.Fields {
 		if field.IsPK {
-			value, err := m.GetFieldValue(model, field.Name)
+			value, err := m.GetFieldValue(model, field.FieldName)
 			return field.Name, value, err
 		}
 	}
@@ -294,12 +403,28 @@ func (m *Mapper) GetPrimaryKey(model interface{}) (string, interface{}, error) {
 	return "", nil, fmt.Errorf("no primary key found")
 }
 
+// columnDefinition строит определение колонки с типом и выражениями
+// MATERIALIZED и ALIAS
+func columnDefinition(field FieldInfo) string {
+	columnDef := fmt.Sprintf("`%s` %s", field.Name, field.Type)
+
+	if field.Materialized != "" {
+		columnDef += " MATERIALIZED " + field.Materialized
+	}
+
+	if field.Alias != "" {
+		columnDef += " ALIAS " + field.Alias
+	}
+
+	return columnDef
+}
+
 // BuildCreateTableSQL строит SQL для создания таблицы
 func (m *Mapper) BuildCreateTableSQL(info *TableInfo) string {
 	var columns []string
 
 	for _, field := range info.Fields {
-		columnDef := fmt.Sprintf("`%s` %s", field.Name, field.Type)
+		columnDef := columnDefinition(field)
 
 		if field.IsPK {
 			columnDef += " PRIMARY KEY"
@@ -317,8 +442,8 @@ func (m *Mapper) BuildCreateTableSQL(info *TableInfo) string {
 		engine = string(EngineMergeTree)
 	}
 
-	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n  %s\n) ENGINE = %s",
-		info.Name, strings.Join(columns, ",\n  "), engine)
+	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n) ENGINE = %s",
+		quoteTable(info.database, info.Name), strings.Join(columns, ",\n  "), engine)
 
 	// Добавляем опции движка
 	if len(info.Options) > 0 {
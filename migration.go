@@ -2,7 +2,15 @@ package chorm
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -29,14 +37,41 @@ type MigrationRecord struct {
 	Up       MigrationFunc
 	Down     MigrationFunc
 	Checksum string
+	Version  string   // Версия содержимого Go-миграции: меняйте ее при изменении Up/Down
+	UpSQL    string   // SQL-скрипт Up миграции из AddSQLMigration
+	DownSQL  string   // SQL-скрипт Down миграции из AddSQLMigration
+	Depends  []string // Имена миграций, которые должны быть применены раньше
 }
 
 // Migrator представляет мигратор
 type Migrator struct {
-	db         *DB
-	migrations []MigrationRecord
+	db          *DB
+	migrations  []MigrationRecord
+	lockTable   string
+	lockTimeout time.Duration
+	force       bool
+	dryRun      bool
+
+	allowOutOfOrder bool
+	ignoreUnknown   bool
+
+	cluster     string
+	tableEngine string
 }
 
+// DefaultMigrationLockTimeout задает время ожидания блокировки миграций по умолчанию
+const DefaultMigrationLockTimeout = 5 * time.Minute
+
+const (
+	migrationLockName       = "migrations"
+	migrationLockMinBackoff = 100 * time.Millisecond
+	migrationLockMaxBackoff = 5 * time.Second
+)
+
+// ErrMigrationLockTimeout возвращается, если блокировку миграций не удалось
+// получить за отведенное время
+var ErrMigrationLockTimeout = errors.New("chorm: timed out waiting for migration lock")
+
 // NewMigrator создает новый мигратор
 func NewMigrator(db *DB) *Migrator {
 	return &Migrator{
@@ -45,37 +80,155 @@ func NewMigrator(db *DB) *Migrator {
 	}
 }
 
-// AddMigration добавляет миграцию
+// AddMigration добавляет миграцию. Содержимое Go-функций не участвует в
+// контрольной сумме: чтобы изменение миграции обнаруживалось, задайте
+// Version через AddMigrationRecord
 func (m *Migrator) AddMigration(name string, up, down MigrationFunc) *Migrator {
-	checksum := generateChecksum(name)
-	m.migrations = append(m.migrations, MigrationRecord{
-		Name:     name,
-		Up:       up,
-		Down:     down,
-		Checksum: checksum,
+	return m.AddMigrationRecord(MigrationRecord{
+		Name: name,
+		Up:   up,
+		Down: down,
 	})
+}
+
+// AddMigrationRecord добавляет миграцию, заданную записью (например, с Depends
+// или Version). Если контрольная сумма не указана, она вычисляется по имени
+// и Version
+func (m *Migrator) AddMigrationRecord(record MigrationRecord) *Migrator {
+	if record.Checksum == "" {
+		record.Checksum = migrationChecksum(record.Name, "go", record.Version)
+	}
+	m.migrations = append(m.migrations, record)
+	return m
+}
+
+// Force включает повторное принятие измененных миграций: вместо ошибки
+// ChecksumMismatchError Migrate записывает текущую контрольную сумму
+func (m *Migrator) Force() *Migrator {
+	m.force = true
+	return m
+}
+
+// AllowOutOfOrder разрешает применять миграции, зарегистрированные перед
+// последней примененной: вместо ошибки MigrationOrderError Migrate
+// журналирует предупреждение и применяет их
+func (m *Migrator) AllowOutOfOrder() *Migrator {
+	m.allowOutOfOrder = true
 	return m
 }
 
-// CreateMigrationsTable создает таблицу для отслеживания миграций
+// IgnoreUnknown разрешает примененные миграции, отсутствующие в коде:
+// вместо ошибки MigrationOrderError Migrate журналирует предупреждение
+func (m *Migrator) IgnoreUnknown() *Migrator {
+	m.ignoreUnknown = true
+	return m
+}
+
+// WithCluster создает таблицу миграций ON CLUSTER cluster и изменяет ее
+// записи мутациями ON CLUSTER. Если движок не задан WithTableEngine,
+// используется ReplicatedReplacingMergeTree, чтобы все реплики видели одни
+// и те же примененные миграции. Запросы самих миграций должны указывать
+// ON CLUSTER сами
+func (m *Migrator) WithCluster(cluster string) *Migrator {
+	m.cluster = cluster
+	return m
+}
+
+// WithTableEngine задает движок таблицы миграций, например
+// "ReplicatedMergeTree('/clickhouse/tables/{shard}/migrations', '{replica}')".
+// Если движок не содержит ORDER BY, добавляется ORDER BY name. Таблица с
+// движком семейства Replacing читается с FINAL
+func (m *Migrator) WithTableEngine(engine string) *Migrator {
+	m.tableEngine = engine
+	return m
+}
+
+// WithLock включает блокировку миграций через таблицу lockTable. Перед
+// применением миграций Migrate записывает в нее строку-блокировку и удаляет ее
+// по завершении, поэтому при одновременном запуске нескольких экземпляров
+// миграции применяет только один из них, а остальные ждут освобождения
+func (m *Migrator) WithLock(lockTable string) *Migrator {
+	m.lockTable = lockTable
+	if m.lockTimeout == 0 {
+		m.lockTimeout = DefaultMigrationLockTimeout
+	}
+	return m
+}
+
+// LockTimeout устанавливает максимальное время ожидания блокировки миграций
+func (m *Migrator) LockTimeout(timeout time.Duration) *Migrator {
+	m.lockTimeout = timeout
+	return m
+}
+
+// CreateMigrationsTable создает таблицу для отслеживания миграций с
+// движком и кластером из WithTableEngine и WithCluster
 func (m *Migrator) CreateMigrationsTable(ctx context.Context) error {
-	return m.db.CreateTable(ctx, &Migration{})
+	if m.cluster == "" && m.tableEngine == "" {
+		return m.db.CreateTable(ctx, &Migration{})
+	}
+
+	engine := m.migrationsEngine()
+	if !strings.Contains(strings.ToUpper(engine), "ORDER BY") {
+		engine += " ORDER BY name"
+	}
+	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS migrations%s (\n  id UInt64,\n  name String,\n  applied_at DateTime,\n  checksum String\n) ENGINE = %s",
+		m.onCluster(), engine)
+	if _, err := m.db.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+	return nil
+}
+
+// migrationsEngine возвращает движок таблицы миграций
+func (m *Migrator) migrationsEngine() string {
+	if m.tableEngine == "" && m.cluster != "" {
+		return "ReplicatedReplacingMergeTree"
+	}
+	return m.tableEngine
+}
+
+// onCluster возвращает секцию ON CLUSTER для запросов к таблице миграций
+func (m *Migrator) onCluster() string {
+	if m.cluster == "" {
+		return ""
+	}
+	return " ON CLUSTER " + m.cluster
+}
+
+// migrationsFrom возвращает таблицу миграций для FROM: движки семейства
+// Replacing читаются с FINAL, чтобы не видеть дубликаты до слияния
+func (m *Migrator) migrationsFrom() string {
+	if strings.Contains(m.migrationsEngine(), "Replacing") {
+		return "migrations FINAL"
+	}
+	return "migrations"
 }
 
 // GetAppliedMigrations получает список примененных миграций
 func (m *Migrator) GetAppliedMigrations(ctx context.Context) ([]Migration, error) {
 	var migrations []Migration
-	err := m.db.Query(ctx, &migrations, "SELECT * FROM migrations ORDER BY id")
+	err := m.db.Query(ctx, &migrations, "SELECT * FROM "+m.migrationsFrom()+" ORDER BY id, applied_at")
 	return migrations, err
 }
 
 // IsMigrationApplied проверяет, применена ли миграция
 func (m *Migrator) IsMigrationApplied(ctx context.Context, name string) (bool, error) {
 	var count int64
-	err := m.db.QueryRow(ctx, &count, "SELECT COUNT(*) FROM migrations WHERE name = ?", name)
+	err := m.db.QueryRow(ctx, &count, "SELECT COUNT(*) FROM "+m.migrationsFrom()+" WHERE name = ?", name)
 	return count > 0, err
 }
 
+// nextMigrationID возвращает идентификатор следующей примененной миграции:
+// на единицу больше наибольшего записанного
+func (m *Migrator) nextMigrationID(ctx context.Context) (uint64, error) {
+	var id uint64
+	if err := m.db.QueryRow(ctx, &id, "SELECT max(id) FROM "+m.migrationsFrom()); err != nil {
+		return 0, fmt.Errorf("failed to get last migration id: %w", err)
+	}
+	return id + 1, nil
+}
+
 // ApplyMigration применяет миграцию
 func (m *Migrator) ApplyMigration(ctx context.Context, migration MigrationRecord) error {
 	// Проверяем, не применена ли уже миграция
@@ -88,28 +241,27 @@ func (m *Migrator) ApplyMigration(ctx context.Context, migration MigrationRecord
 		return fmt.Errorf("migration %s is already applied", migration.Name)
 	}
 
-	// Начинаем транзакцию
-	tx, err := m.db.Begin(ctx)
+	id, err := m.nextMigrationID(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
 
-	// Выполняем миграцию
+	// Выполняем миграцию. В ClickHouse нет транзакций, охватывающих DDL,
+	// поэтому запись о миграции добавляется после Up, а при ошибке записи
+	// изменения откатываются через Down
 	if err := migration.Up(ctx, m.db); err != nil {
 		return fmt.Errorf("failed to apply migration %s: %w", migration.Name, err)
 	}
 
 	// Записываем информацию о миграции
-	_, err = tx.Exec(ctx,
-		"INSERT INTO migrations (name, applied_at, checksum) VALUES (?, ?, ?)",
-		migration.Name, time.Now(), migration.Checksum)
+	_, err = m.db.Exec(ctx,
+		"INSERT INTO migrations (id, name, applied_at, checksum) VALUES (?, ?, ?, ?)",
+		id, migration.Name, time.Now(), migration.Checksum)
 	if err != nil {
-		return fmt.Errorf("failed to record migration: %w", err)
+		return m.compensate(ctx, migration, MigrationDirectionUp, fmt.Errorf("failed to record migration: %w", err))
 	}
 
-	// Подтверждаем транзакцию
-	return tx.Commit()
+	return nil
 }
 
 // RollbackMigration откатывает миграцию
@@ -136,58 +288,204 @@ func (m *Migrator) RollbackMigration(ctx context.Context, name string) error {
 	if migration.Name == "" {
 		return fmt.Errorf("migration %s not found", name)
 	}
-
-	// Начинаем транзакцию
-	tx, err := m.db.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+	if migration.Down == nil {
+		return &IrreversibleMigrationError{Names: []string{name}}
 	}
-	defer tx.Rollback()
 
-	// Выполняем откат
-	if migration.Down != nil {
-		if err := migration.Down(ctx, m.db); err != nil {
-			return fmt.Errorf("failed to rollback migration %s: %w", migration.Name, err)
-		}
+	// Выполняем откат. При ошибке удаления записи изменения повторно
+	// применяются через Up
+	if err := migration.Down(ctx, m.db); err != nil {
+		return fmt.Errorf("failed to rollback migration %s: %w", migration.Name, err)
 	}
 
 	// Удаляем запись о миграции
-	_, err = tx.Exec(ctx, "DELETE FROM migrations WHERE name = ?", name)
+	_, err = m.db.Exec(ctx, "DELETE FROM migrations"+m.onCluster()+" WHERE name = ?", name)
 	if err != nil {
-		return fmt.Errorf("failed to remove migration record: %w", err)
+		return m.compensate(ctx, migration, MigrationDirectionDown, fmt.Errorf("failed to remove migration record: %w", err))
+	}
+
+	return nil
+}
+
+// MigrationDirection - направление миграции: применение (Up) или откат (Down)
+type MigrationDirection string
+
+const (
+	MigrationDirectionUp   MigrationDirection = "up"
+	MigrationDirectionDown MigrationDirection = "down"
+)
+
+// PartialMigrationError сообщает, что Up (или Down) миграции выполнен, но
+// таблицу миграций обновить не удалось. Мигратор пытается вернуть схему в
+// состояние, соответствующее таблице миграций, обратной функцией:
+// Compensated означает, что это удалось. Иначе схема и таблица миграций
+// расходятся и их нужно исправить вручную: CompensateErr содержит ошибку
+// обратной функции (nil, если ее нет)
+type PartialMigrationError struct {
+	Name          string
+	Direction     MigrationDirection
+	RecordErr     error
+	Compensated   bool
+	CompensateErr error
+}
+
+func (e *PartialMigrationError) Error() string {
+	reverse := MigrationDirectionDown
+	state := "schema is changed but not recorded"
+	if e.Direction == MigrationDirectionDown {
+		reverse = MigrationDirectionUp
+		state = "schema is rolled back but still recorded as applied"
 	}
 
-	// Подтверждаем транзакцию
-	return tx.Commit()
+	msg := fmt.Sprintf("migration %s %s ran but its record was not updated: %v", e.Name, e.Direction, e.RecordErr)
+	switch {
+	case e.Compensated:
+		return fmt.Sprintf("%s; %s reverted the change", msg, reverse)
+	case e.CompensateErr != nil:
+		return fmt.Sprintf("%s; %s failed: %v; %s", msg, reverse, e.CompensateErr, state)
+	default:
+		return fmt.Sprintf("%s; no %s function, %s", msg, reverse, state)
+	}
+}
+
+func (e *PartialMigrationError) Unwrap() error {
+	return e.RecordErr
+}
+
+// compensate после ошибки записи recordErr выполняет обратную функцию
+// миграции и возвращает PartialMigrationError с результатами обоих шагов
+func (m *Migrator) compensate(ctx context.Context, migration MigrationRecord, direction MigrationDirection, recordErr error) error {
+	partial := &PartialMigrationError{
+		Name:      migration.Name,
+		Direction: direction,
+		RecordErr: recordErr,
+	}
+
+	reverse := migration.Down
+	if direction == MigrationDirectionDown {
+		reverse = migration.Up
+	}
+	if reverse != nil {
+		if err := reverse(ctx, m.db); err != nil {
+			partial.CompensateErr = err
+		} else {
+			partial.Compensated = true
+		}
+	}
+
+	return partial
 }
 
 // Migrate применяет все непримененные миграции
 func (m *Migrator) Migrate(ctx context.Context) error {
+	return m.migrate(ctx, func(pending, migrations []MigrationRecord) ([]MigrationRecord, error) {
+		return pending, nil
+	})
+}
+
+// MigrateTo применяет непримененные миграции по порядку до миграции name
+// включительно. Миграции после name остаются непримененными
+func (m *Migrator) MigrateTo(ctx context.Context, name string) error {
+	return m.migrate(ctx, func(pending, migrations []MigrationRecord) ([]MigrationRecord, error) {
+		position := make(map[string]int, len(migrations))
+		for i, migration := range migrations {
+			position[migration.Name] = i
+		}
+		target, ok := position[name]
+		if !ok {
+			return nil, fmt.Errorf("migration %s not found", name)
+		}
+
+		var selected []MigrationRecord
+		for _, migration := range pending {
+			if position[migration.Name] <= target {
+				selected = append(selected, migration)
+			}
+		}
+		return selected, nil
+	})
+}
+
+// Steps применяет n следующих непримененных миграций, а при отрицательном n
+// откатывает -n последних примененных (как RollbackN)
+func (m *Migrator) Steps(ctx context.Context, n int) error {
+	if n < 0 {
+		return m.RollbackN(ctx, -n)
+	}
+	if n == 0 {
+		return fmt.Errorf("invalid steps count %d", n)
+	}
+
+	return m.migrate(ctx, func(pending, migrations []MigrationRecord) ([]MigrationRecord, error) {
+		if n > len(pending) {
+			return nil, fmt.Errorf("cannot apply %d migrations: only %d pending", n, len(pending))
+		}
+		return pending[:n], nil
+	})
+}
+
+// migrate применяет непримененные миграции, выбранные функцией selectPending
+// из непримененных (pending) в порядке применения. migrations - все миграции
+// в порядке применения
+func (m *Migrator) migrate(ctx context.Context, selectPending func(pending, migrations []MigrationRecord) ([]MigrationRecord, error)) error {
+	if m.dryRun {
+		pending, migrations, err := m.pendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		if pending, err = selectPending(pending, migrations); err != nil {
+			return err
+		}
+		m.logPlan(plannedMigrations(pending))
+		return nil
+	}
+
 	// Создаем таблицу миграций, если она не существует
 	if err := m.CreateMigrationsTable(ctx); err != nil {
 		return fmt.Errorf("failed to create migrations table: %w", err)
 	}
 
+	// Захватываем блокировку, если она включена
+	if m.lockTable != "" {
+		release, err := m.acquireLock(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
 	// Получаем примененные миграции
 	applied, err := m.GetAppliedMigrations(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get applied migrations: %w", err)
 	}
 
-	// Создаем карту примененных миграций
-	appliedMap := make(map[string]bool)
-	for _, migration := range applied {
-		appliedMap[migration.Name] = true
+	// Проверяем, что примененные миграции не были изменены, и обновляем
+	// контрольные суммы старого формата
+	if err := m.upgradeChecksums(ctx, applied); err != nil {
+		return err
 	}
 
-	// Применяем непримененные миграции
-	for _, migration := range m.migrations {
-		if !appliedMap[migration.Name] {
-			if err := m.ApplyMigration(ctx, migration); err != nil {
-				return fmt.Errorf("failed to apply migration %s: %w", migration.Name, err)
-			}
-			fmt.Printf("Applied migration: %s\n", migration.Name)
+	// Упорядочиваем миграции с учетом зависимостей
+	migrations, err := m.sortedMigrations()
+	if err != nil {
+		return err
+	}
+
+	pending := unappliedMigrations(applied, migrations)
+	if err := m.checkOrder(applied, migrations); err != nil {
+		return err
+	}
+	if pending, err = selectPending(pending, migrations); err != nil {
+		return err
+	}
+
+	// Применяем выбранные миграции
+	for _, migration := range pending {
+		if err := m.ApplyMigration(ctx, migration); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", migration.Name, err)
 		}
+		m.db.infof("Applied migration: %s", migration.Name)
 	}
 
 	return nil
@@ -195,60 +493,592 @@ func (m *Migrator) Migrate(ctx context.Context) error {
 
 // Rollback откатывает последнюю миграцию
 func (m *Migrator) Rollback(ctx context.Context) error {
+	return m.RollbackN(ctx, 1)
+}
+
+// RollbackN откатывает n последних примененных миграций в обратном порядке.
+// Каждый откат подтверждается до начала следующего, поэтому при ошибке уже
+// откаченные миграции остаются откаченными
+func (m *Migrator) RollbackN(ctx context.Context, n int) error {
+	_, err := m.RollbackSteps(ctx, n)
+	return err
+}
+
+// RollbackSteps откатывает n последних примененных миграций в обратном
+// порядке, как RollbackN, и возвращает имена откаченных миграций. При ошибке
+// откат останавливается: возвращаются миграции, откаченные до нее, а ошибка
+// содержит имя миграции, на которой он остановился
+func (m *Migrator) RollbackSteps(ctx context.Context, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid rollback count %d", n)
+	}
+
 	// Получаем примененные миграции
 	applied, err := m.GetAppliedMigrations(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get applied migrations: %w", err)
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
 	}
 
 	if len(applied) == 0 {
-		return fmt.Errorf("no migrations to rollback")
+		return nil, fmt.Errorf("no migrations to rollback")
+	}
+	if n > len(applied) {
+		return nil, fmt.Errorf("cannot rollback %d migrations: only %d applied", n, len(applied))
 	}
 
-	// Откатываем последнюю миграцию
-	lastMigration := applied[len(applied)-1]
-	return m.RollbackMigration(ctx, lastMigration.Name)
+	return m.rollbackApplied(ctx, applied[len(applied)-n:])
 }
 
-// Status показывает статус миграций
-func (m *Migrator) Status(ctx context.Context) error {
+// RollbackTo откатывает все миграции, примененные после миграции name.
+// Сама миграция name остается примененной (в отличие от MigrateTo, где
+// целевая миграция включается)
+func (m *Migrator) RollbackTo(ctx context.Context, name string) error {
+	// Получаем примененные миграции
+	applied, err := m.GetAppliedMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	for i, migration := range applied {
+		if migration.Name == name {
+			_, err := m.rollbackApplied(ctx, applied[i+1:])
+			return err
+		}
+	}
+
+	return fmt.Errorf("migration %s is not applied", name)
+}
+
+// rollbackApplied откатывает примененные миграции начиная с последней и
+// возвращает имена откаченных. Если у какой-либо из них нет Down, откат не
+// начинается
+func (m *Migrator) rollbackApplied(ctx context.Context, applied []Migration) ([]string, error) {
+	if err := m.checkReversible(applied); err != nil {
+		return nil, err
+	}
+
+	var rolledBack []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		if err := m.RollbackMigration(ctx, applied[i].Name); err != nil {
+			return rolledBack, fmt.Errorf("rollback stopped at %s after %d of %d migrations: %w",
+				applied[i].Name, len(rolledBack), len(applied), err)
+		}
+		rolledBack = append(rolledBack, applied[i].Name)
+		m.db.infof("Rolled back migration: %s", applied[i].Name)
+	}
+	return rolledBack, nil
+}
+
+// unappliedMigrations возвращает миграции из migrations, которых нет среди
+// примененных, сохраняя порядок migrations
+func unappliedMigrations(applied []Migration, migrations []MigrationRecord) []MigrationRecord {
+	appliedMap := make(map[string]bool, len(applied))
+	for _, migration := range applied {
+		appliedMap[migration.Name] = true
+	}
+
+	var pending []MigrationRecord
+	for _, migration := range migrations {
+		if !appliedMap[migration.Name] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+// MigrationStatus описывает состояние миграции. Checksum - записанная в
+// таблице миграций контрольная сумма для примененной миграции и текущая для
+// ожидающей. Missing отмечает миграцию, которая применена, но отсутствует в
+// коде
+type MigrationStatus struct {
+	Name             string
+	Applied          bool
+	AppliedAt        time.Time
+	Checksum         string
+	ChecksumMismatch bool
+	Missing          bool
+}
+
+// StatusList возвращает статус миграций: зарегистрированные миграции в
+// порядке применения, затем примененные миграции, отсутствующие в коде.
+// Контрольная сумма в старом формате не считается расхождением
+func (m *Migrator) StatusList(ctx context.Context) ([]MigrationStatus, error) {
 	// Создаем таблицу миграций, если она не существует
 	if err := m.CreateMigrationsTable(ctx); err != nil {
-		return fmt.Errorf("failed to create migrations table: %w", err)
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
 	}
 
-	// Получаем примененные миграции
 	applied, err := m.GetAppliedMigrations(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get applied migrations: %w", err)
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	migrations, err := m.sortedMigrations()
+	if err != nil {
+		return nil, err
 	}
 
-	// Создаем карту примененных миграций
-	appliedMap := make(map[string]Migration)
+	appliedMap := make(map[string]Migration, len(applied))
 	for _, migration := range applied {
 		appliedMap[migration.Name] = migration
 	}
 
-	fmt.Println("Migration Status:")
-	fmt.Println("==================")
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	known := make(map[string]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Name] = true
 
-	for _, migration := range m.migrations {
-		if applied, exists := appliedMap[migration.Name]; exists {
-			fmt.Printf("✓ %s (applied at %s)\n", migration.Name, applied.AppliedAt.Format("2006-01-02 15:04:05"))
-		} else {
-			fmt.Printf("✗ %s (pending)\n", migration.Name)
+		record, ok := appliedMap[migration.Name]
+		if !ok {
+			statuses = append(statuses, MigrationStatus{Name: migration.Name, Checksum: migration.Checksum})
+			continue
 		}
+		statuses = append(statuses, MigrationStatus{
+			Name:      migration.Name,
+			Applied:   true,
+			AppliedAt: record.AppliedAt,
+			Checksum:  record.Checksum,
+			ChecksumMismatch: record.Checksum != migration.Checksum &&
+				record.Checksum != legacyChecksum(migration.Name),
+		})
 	}
 
+	for _, record := range applied {
+		if known[record.Name] {
+			continue
+		}
+		known[record.Name] = true
+		statuses = append(statuses, MigrationStatus{
+			Name:      record.Name,
+			Applied:   true,
+			AppliedAt: record.AppliedAt,
+			Checksum:  record.Checksum,
+			Missing:   true,
+		})
+	}
+
+	return statuses, nil
+}
+
+// Pending возвращает имена непримененных миграций в порядке применения.
+// Таблица миграций не создается, а ее расхождения с кодом (измененные
+// миграции, миграции вне порядка) возвращаются ошибкой, как в Migrate.
+// Подходит для проверки готовности сервиса после развертывания
+func (m *Migrator) Pending(ctx context.Context) ([]string, error) {
+	pending, _, err := m.pendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(pending))
+	for i, migration := range pending {
+		names[i] = migration.Name
+	}
+	return names, nil
+}
+
+// PrintStatus выводит статус миграций через логгер
+func (m *Migrator) PrintStatus(ctx context.Context) error {
+	statuses, err := m.StatusList(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.db.infof("Migration Status:")
+	for _, status := range statuses {
+		appliedAt := status.AppliedAt.Format("2006-01-02 15:04:05")
+		switch {
+		case status.Missing:
+			m.db.infof("? %s (applied at %s, missing in code)", status.Name, appliedAt)
+		case status.ChecksumMismatch:
+			m.db.infof("! %s (applied at %s, checksum mismatch)", status.Name, appliedAt)
+		case status.Applied:
+			m.db.infof("✓ %s (applied at %s)", status.Name, appliedAt)
+		default:
+			m.db.infof("✗ %s (pending)", status.Name)
+		}
+	}
+
+	return nil
+}
+
+// Status выводит статус миграций через логгер, как PrintStatus
+func (m *Migrator) Status(ctx context.Context) error {
+	return m.PrintStatus(ctx)
+}
+
+// IrreversibleMigrationError возвращается при попытке отката миграций без
+// Down (или не зарегистрированных в миграторе). Откат в этом случае не
+// выполняется
+type IrreversibleMigrationError struct {
+	Names []string
+}
+
+func (e *IrreversibleMigrationError) Error() string {
+	return fmt.Sprintf("cannot rollback migrations without down: %s", strings.Join(e.Names, ", "))
+}
+
+// checkReversible проверяет, что у всех миграций applied есть Down
+func (m *Migrator) checkReversible(applied []Migration) error {
+	records := make(map[string]MigrationRecord, len(m.migrations))
+	for _, migration := range m.migrations {
+		records[migration.Name] = migration
+	}
+
+	var blockers []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		if records[applied[i].Name].Down == nil {
+			blockers = append(blockers, applied[i].Name)
+		}
+	}
+	if len(blockers) > 0 {
+		return &IrreversibleMigrationError{Names: blockers}
+	}
 	return nil
 }
 
-// generateChecksum генерирует контрольную сумму для миграции
-func generateChecksum(name string) string {
-	// Простая реализация - в реальном проекте можно использовать более сложные алгоритмы
+// MigrationCycleError сообщает о цикле в зависимостях миграций
+type MigrationCycleError struct {
+	Cycle []string // Миграции цикла; первая повторяется в конце
+}
+
+func (e *MigrationCycleError) Error() string {
+	return fmt.Sprintf("migration dependency cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// sortedMigrations возвращает миграции в порядке применения: каждая миграция
+// идет после своих зависимостей, а в остальном миграции упорядочены по
+// версии (см. migrationLess). Повторяющееся имя миграции возвращает ошибку
+func (m *Migrator) sortedMigrations() ([]MigrationRecord, error) {
+	byName := make(map[string]MigrationRecord, len(m.migrations))
+	for _, migration := range m.migrations {
+		if _, ok := byName[migration.Name]; ok {
+			return nil, fmt.Errorf("duplicate migration name %s", migration.Name)
+		}
+		byName[migration.Name] = migration
+	}
+
+	candidates := append([]MigrationRecord(nil), m.migrations...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return migrationLess(candidates[i].Name, candidates[j].Name)
+	})
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(m.migrations))
+	sorted := make([]MigrationRecord, 0, len(m.migrations))
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			// Выделяем цикл из текущего пути обхода
+			for i, n := range path {
+				if n == name {
+					cycle := append(append([]string{}, path[i:]...), name)
+					return &MigrationCycleError{Cycle: cycle}
+				}
+			}
+		}
+
+		migration := byName[name]
+		state[name] = visiting
+		path = append(path, name)
+
+		for _, dep := range migration.Depends {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("migration %s depends on unknown migration %s", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[name] = visited
+		sorted = append(sorted, migration)
+		return nil
+	}
+
+	for _, migration := range candidates {
+		if err := visit(migration.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// migrationLess сравнивает имена миграций по версии: числовые префиксы
+// (001_init, 10_add_email) сравниваются как числа, остальное - как строки
+func migrationLess(a, b string) bool {
+	aVersion, aRest := migrationVersion(a)
+	bVersion, bRest := migrationVersion(b)
+	if aVersion != "" && bVersion != "" {
+		aNumber, _ := strconv.ParseUint(aVersion, 10, 64)
+		bNumber, _ := strconv.ParseUint(bVersion, 10, 64)
+		if aNumber != bNumber {
+			return aNumber < bNumber
+		}
+		return aRest < bRest
+	}
+	return a < b
+}
+
+// migrationVersion разделяет имя миграции на числовой префикс и остаток
+func migrationVersion(name string) (version, rest string) {
+	i := 0
+	for i < len(name) && name[i] >= '0' && name[i] <= '9' {
+		i++
+	}
+	return name[:i], name[i:]
+}
+
+// acquireLock захватывает блокировку миграций, ожидая ее освобождения с
+// экспоненциальной задержкой, и возвращает функцию освобождения.
+// ClickHouse не поддерживает уникальные ключи, поэтому строка вставляется
+// только при отсутствии блокировки, а владельцем считается самая ранняя
+// строка: проигравший гонку удаляет свою строку и ждет
+func (m *Migrator) acquireLock(ctx context.Context) (func(), error) {
+	createSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  name String,\n  owner String,\n  locked_at DateTime64(3)\n) ENGINE = MergeTree() ORDER BY name", m.lockTable)
+	if _, err := m.db.Exec(ctx, createSQL); err != nil {
+		return nil, fmt.Errorf("failed to create migration lock table: %w", err)
+	}
+
+	owner, err := newLockOwner()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate migration lock owner: %w", err)
+	}
+
+	insertSQL := fmt.Sprintf("INSERT INTO %s (name, owner, locked_at) SELECT ?, ?, now64(3) WHERE NOT EXISTS (SELECT 1 FROM %s WHERE name = ?)", m.lockTable, m.lockTable)
+	holderSQL := fmt.Sprintf("SELECT owner FROM %s WHERE name = ? ORDER BY locked_at, owner LIMIT 1", m.lockTable)
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE name = ? AND owner = ?", m.lockTable)
+
+	release := func() {
+		// Блокировка должна быть снята даже при отмене ctx
+		if _, err := m.db.Exec(context.Background(), deleteSQL, migrationLockName, owner); err != nil {
+			m.db.errorf("Failed to release migration lock: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(m.lockTimeout)
+	for attempt := 0; ; attempt++ {
+		if _, err := m.db.Exec(ctx, insertSQL, migrationLockName, owner, migrationLockName); err != nil {
+			return nil, fmt.Errorf("failed to insert migration lock: %w", err)
+		}
+
+		var holder string
+		if err := m.db.QueryRow(ctx, &holder, holderSQL, migrationLockName); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			release()
+			return nil, fmt.Errorf("failed to read migration lock: %w", err)
+		}
+		if holder == owner {
+			return release, nil
+		}
+
+		// Блокировку держит другой экземпляр: убираем свою строку, если она
+		// успела попасть в таблицу, и ждем
+		release()
+
+		wait := migrationLockBackoff(attempt)
+		if time.Now().Add(wait).After(deadline) {
+			return nil, fmt.Errorf("%w (held by %s)", ErrMigrationLockTimeout, holder)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// migrationLockBackoff возвращает задержку перед попыткой attempt
+func migrationLockBackoff(attempt int) time.Duration {
+	wait := migrationLockMinBackoff
+	for i := 0; i < attempt && wait < migrationLockMaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > migrationLockMaxBackoff {
+		wait = migrationLockMaxBackoff
+	}
+	return wait
+}
+
+// newLockOwner генерирует уникальный идентификатор владельца блокировки
+func newLockOwner() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(buf)), nil
+}
+
+// migrationChecksum возвращает SHA-256 имени миграции и ее объявленного
+// содержимого: вида миграции и текста SQL или версии Go-миграции
+func migrationChecksum(name string, content ...string) string {
+	h := sha256.New()
+	h.Write([]byte(name))
+	for _, part := range content {
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// legacyChecksum возвращает контрольную сумму в старом формате (длина имени),
+// который записывался ранними версиями
+func legacyChecksum(name string) string {
 	return fmt.Sprintf("%d", len(name))
 }
 
+// ChecksumMismatchError сообщает, что примененная миграция была изменена
+type ChecksumMismatchError struct {
+	Name     string
+	Stored   string
+	Expected string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("migration %s was modified after being applied: stored checksum %s, current %s", e.Name, e.Stored, e.Expected)
+}
+
+// MigrationOrderError сообщает о расхождении таблицы миграций с кодом:
+// OutOfOrder - непримененные миграции, зарегистрированные перед последней
+// примененной LastApplied, Unknown - примененные миграции, отсутствующие в коде
+type MigrationOrderError struct {
+	OutOfOrder  []string
+	LastApplied string
+	Unknown     []string
+}
+
+func (e *MigrationOrderError) Error() string {
+	var parts []string
+	if len(e.OutOfOrder) > 0 {
+		parts = append(parts, fmt.Sprintf("pending migrations precede applied migration %s: %s",
+			e.LastApplied, strings.Join(e.OutOfOrder, ", ")))
+	}
+	if len(e.Unknown) > 0 {
+		parts = append(parts, fmt.Sprintf("applied migrations are missing from code: %s",
+			strings.Join(e.Unknown, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// checkOrder проверяет, что непримененные миграции идут после последней
+// примененной (в порядке sortedMigrations), а все примененные миграции
+// зарегистрированы. Разрешенные AllowOutOfOrder и IgnoreUnknown расхождения
+// журналируются предупреждением
+func (m *Migrator) checkOrder(applied []Migration, migrations []MigrationRecord) error {
+	appliedMap := make(map[string]bool, len(applied))
+	for _, migration := range applied {
+		appliedMap[migration.Name] = true
+	}
+
+	last := -1
+	known := make(map[string]bool, len(migrations))
+	for i, migration := range migrations {
+		known[migration.Name] = true
+		if appliedMap[migration.Name] {
+			last = i
+		}
+	}
+
+	orderErr := &MigrationOrderError{}
+	for i := 0; i < last; i++ {
+		if !appliedMap[migrations[i].Name] {
+			orderErr.OutOfOrder = append(orderErr.OutOfOrder, migrations[i].Name)
+		}
+	}
+	if len(orderErr.OutOfOrder) > 0 {
+		orderErr.LastApplied = migrations[last].Name
+	}
+	for _, migration := range applied {
+		if !known[migration.Name] {
+			orderErr.Unknown = append(orderErr.Unknown, migration.Name)
+		}
+	}
+
+	if len(orderErr.OutOfOrder) > 0 && m.allowOutOfOrder {
+		m.db.warnf("Applying migrations out of order (registered before applied migration %s): %s",
+			orderErr.LastApplied, strings.Join(orderErr.OutOfOrder, ", "))
+		orderErr.OutOfOrder, orderErr.LastApplied = nil, ""
+	}
+	if len(orderErr.Unknown) > 0 && m.ignoreUnknown {
+		m.db.warnf("Applied migrations are missing from code: %s", strings.Join(orderErr.Unknown, ", "))
+		orderErr.Unknown = nil
+	}
+
+	if len(orderErr.OutOfOrder) > 0 || len(orderErr.Unknown) > 0 {
+		return orderErr
+	}
+	return nil
+}
+
+// validateChecksums сравнивает сохраненные контрольные суммы с текущими
+func (m *Migrator) validateChecksums(applied []Migration) error {
+	_, err := m.staleChecksums(applied)
+	return err
+}
+
+// staleChecksums возвращает примененные миграции, контрольную сумму которых
+// нужно перезаписать: записанные в старом формате (длина имени) и, при
+// Force, измененные. Для измененной миграции без Force
+// возвращается ChecksumMismatchError
+func (m *Migrator) staleChecksums(applied []Migration) ([]MigrationRecord, error) {
+	stored := make(map[string]string, len(applied))
+	for _, migration := range applied {
+		stored[migration.Name] = migration.Checksum
+	}
+
+	var stale []MigrationRecord
+	for _, migration := range m.migrations {
+		checksum, ok := stored[migration.Name]
+		switch {
+		case !ok || checksum == migration.Checksum:
+			continue
+		case checksum == legacyChecksum(migration.Name):
+			stale = append(stale, migration)
+		case m.force:
+			m.db.warnf("Migration %s was modified after being applied, accepting the new checksum", migration.Name)
+			stale = append(stale, migration)
+		default:
+			return nil, &ChecksumMismatchError{
+				Name:     migration.Name,
+				Stored:   checksum,
+				Expected: migration.Checksum,
+			}
+		}
+	}
+
+	return stale, nil
+}
+
+// upgradeChecksums проверяет контрольные суммы примененных миграций и
+// записывает текущие вместо устаревших
+func (m *Migrator) upgradeChecksums(ctx context.Context, applied []Migration) error {
+	stale, err := m.staleChecksums(applied)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range stale {
+		_, err := m.db.Exec(ctx,
+			"ALTER TABLE migrations"+m.onCluster()+" UPDATE checksum = ? WHERE name = ? SETTINGS mutations_sync = 1",
+			migration.Checksum, migration.Name)
+		if err != nil {
+			return fmt.Errorf("failed to update checksum of migration %s: %w", migration.Name, err)
+		}
+	}
+	return nil
+}
+
 // Schema представляет схему базы данных
 type Schema struct {
 	db *DB
@@ -325,6 +1155,20 @@ func (s *Schema) DropColumn(ctx context.Context, tableName, columnName string) e
 	return err
 }
 
+// AddMaterializedColumn добавляет колонку MATERIALIZED, вычисляемую из выражения
+func (s *Schema) AddMaterializedColumn(ctx context.Context, tableName, columnName, columnType, expr string) error {
+	sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s MATERIALIZED %s", tableName, columnName, columnType, expr)
+	_, err := s.db.Exec(ctx, sql)
+	return err
+}
+
+// AddAliasColumn добавляет колонку ALIAS, вычисляемую при чтении
+func (s *Schema) AddAliasColumn(ctx context.Context, tableName, columnName, columnType, expr string) error {
+	sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s ALIAS %s", tableName, columnName, columnType, expr)
+	_, err := s.db.Exec(ctx, sql)
+	return err
+}
+
 // ModifyColumn изменяет тип колонки
 func (s *Schema) ModifyColumn(ctx context.Context, tableName, columnName, newType string) error {
 	sql := fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", tableName, columnName, newType)
@@ -394,3 +1238,93 @@ func (s *Schema) GetDatabases(ctx context.Context) ([]string, error) {
 	err := s.db.Query(ctx, &databases, "SHOW DATABASES")
 	return databases, err
 }
+
+// DatabaseInfo описывает базу данных из system.databases
+type DatabaseInfo struct {
+	Name         string `ch:"name"`
+	Engine       string `ch:"engine"`
+	DataPath     string `ch:"data_path"`
+	MetadataPath string `ch:"metadata_path"`
+	UUID         string `ch:"uuid"`
+}
+
+// TableListing описывает таблицу из system.tables. Для представлений и
+// таблиц без статистики TotalRows и TotalBytes равны нулю
+type TableListing struct {
+	Database                 string    `ch:"database"`
+	Name                     string    `ch:"name"`
+	Engine                   string    `ch:"engine"`
+	UUID                     string    `ch:"uuid"`
+	TotalRows                uint64    `ch:"total_rows"`
+	TotalBytes               uint64    `ch:"total_bytes"`
+	MetadataModificationTime time.Time `ch:"metadata_modification_time"`
+}
+
+// ShowDatabases возвращает базы данных, имена которых соответствуют шаблону
+// LIKE pattern (все базы, если pattern пуст), как SHOW DATABASES LIKE, но с
+// движком, путями и UUID из system.databases
+func (s *Schema) ShowDatabases(ctx context.Context, pattern string) ([]DatabaseInfo, error) {
+	sql := "SELECT name, engine, data_path, metadata_path, toString(uuid) AS uuid FROM system.databases"
+	var args []interface{}
+	if pattern != "" {
+		sql += " WHERE name LIKE ?"
+		args = append(args, pattern)
+	}
+	sql += " ORDER BY name"
+
+	var databases []DatabaseInfo
+	if err := s.db.Query(ctx, &databases, sql, args...); err != nil {
+		return nil, fmt.Errorf("failed to show databases: %w", err)
+	}
+	return databases, nil
+}
+
+// ShowTables возвращает таблицы базы dbName (текущей базы, если dbName
+// пусто), имена которых соответствуют шаблону LIKE pattern, как SHOW TABLES
+// FROM dbName LIKE, но с движком и размером из system.tables
+func (s *Schema) ShowTables(ctx context.Context, dbName, pattern string) ([]TableListing, error) {
+	where := "database = currentDatabase()"
+	var args []interface{}
+	if dbName != "" {
+		where = "database = ?"
+		args = append(args, dbName)
+	}
+	if pattern != "" {
+		where += " AND name LIKE ?"
+		args = append(args, pattern)
+	}
+
+	sql := "SELECT database, name, engine, toString(uuid) AS uuid, " +
+		"ifNull(total_rows, 0) AS total_rows, ifNull(total_bytes, 0) AS total_bytes, " +
+		"metadata_modification_time FROM system.tables WHERE " + where + " ORDER BY name"
+
+	var tables []TableListing
+	if err := s.db.Query(ctx, &tables, sql, args...); err != nil {
+		return nil, fmt.Errorf("failed to show tables: %w", err)
+	}
+	return tables, nil
+}
+
+// ShowCreate возвращает DDL таблицы (SHOW CREATE TABLE)
+func (s *Schema) ShowCreate(ctx context.Context, tableName string) (string, error) {
+	return s.showCreate(ctx, "TABLE", tableName)
+}
+
+// ShowCreateView возвращает DDL представления (SHOW CREATE VIEW)
+func (s *Schema) ShowCreateView(ctx context.Context, viewName string) (string, error) {
+	return s.showCreate(ctx, "VIEW", viewName)
+}
+
+// ShowCreateDictionary возвращает DDL словаря (SHOW CREATE DICTIONARY)
+func (s *Schema) ShowCreateDictionary(ctx context.Context, name string) (string, error) {
+	return s.showCreate(ctx, "DICTIONARY", name)
+}
+
+// showCreate выполняет SHOW CREATE для объекта kind
+func (s *Schema) showCreate(ctx context.Context, kind, name string) (string, error) {
+	var statement string
+	if err := s.db.QueryRow(ctx, &statement, fmt.Sprintf("SHOW CREATE %s %s", kind, name)); err != nil {
+		return "", fmt.Errorf("failed to show create %s %s: %w", strings.ToLower(kind), name, err)
+	}
+	return statement, nil
+}
@@ -0,0 +1,195 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GenerateFromModels сравнивает колонки моделей с текущей схемой
+// (system.columns) и возвращает SQL-миграцию name: CREATE TABLE для
+// отсутствующих таблиц, ADD COLUMN для новых полей и MODIFY COLUMN для
+// полей с другим типом. Down выполняет обратные операции в обратном порядке;
+// прежние MATERIALIZED и ALIAS выражения при откате MODIFY не
+// восстанавливаются. Колонки, которых нет в моделях, не удаляются и
+// отмечаются комментарием.
+//
+// Миграция не добавляется в мигратор и не применяется: проверьте UpSQL и
+// DownSQL, сохраните их WriteSQLMigration и загрузите через LoadDir. Если
+// изменений нет, возвращается ErrNoSchemaChanges:
+//
+//	record, err := migrator.GenerateFromModels(ctx, "003_sync_users", &User{})
+//	if err == nil {
+//		err = chorm.WriteSQLMigration("migrations", record)
+//	}
+func (m *Migrator) GenerateFromModels(ctx context.Context, name string, models ...interface{}) (MigrationRecord, error) {
+	var up, down []string
+
+	mapper := NewMapper()
+	for _, model := range models {
+		info, err := mapper.ParseStruct(model)
+		if err != nil {
+			return MigrationRecord{}, fmt.Errorf("failed to parse struct: %w", err)
+		}
+
+		columns, err := m.liveColumns(ctx, info.Name)
+		if err != nil {
+			return MigrationRecord{}, err
+		}
+		table := m.db.qualifyTable(info.Name)
+
+		if len(columns.names) == 0 {
+			createSQL, err := m.db.CreateTableSQL(model)
+			if err != nil {
+				return MigrationRecord{}, err
+			}
+			up = append(up, createSQL)
+			down = append(down, "DROP TABLE IF EXISTS "+table)
+			continue
+		}
+
+		modelColumns := make(map[string]bool, len(info.Fields))
+		for _, field := range info.Fields {
+			modelColumns[field.Name] = true
+
+			liveType, ok := columns.types[field.Name]
+			switch {
+			case !ok:
+				up = append(up, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s", table, columnDefinition(field)))
+				down = append(down, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS `%s`", table, field.Name))
+			case !sameColumnType(liveType, field.Type):
+				up = append(up, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", table, columnDefinition(field)))
+				down = append(down, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN `%s` %s", table, field.Name, liveType))
+			}
+		}
+
+		for _, column := range columns.names {
+			if !modelColumns[column] {
+				up = append(up, fmt.Sprintf("-- column %s.%s is not in the model and is kept", info.Name, column))
+			}
+		}
+	}
+
+	if !hasStatements(up) {
+		return MigrationRecord{}, ErrNoSchemaChanges
+	}
+
+	for i, j := 0, len(down)-1; i < j; i, j = i+1, j-1 {
+		down[i], down[j] = down[j], down[i]
+	}
+
+	upSQL, downSQL := joinStatements(up), joinStatements(down)
+	record := MigrationRecord{
+		Name:     name,
+		UpSQL:    upSQL,
+		DownSQL:  downSQL,
+		Up:       sqlMigrationFunc(upSQL),
+		Checksum: migrationChecksum(name, "sql", upSQL, downSQL),
+	}
+	if downSQL != "" {
+		record.Down = sqlMigrationFunc(downSQL)
+	}
+	return record, nil
+}
+
+// WriteSQLMigration записывает UpSQL и DownSQL миграции в файлы
+// NNN_name.up.sql и NNN_name.down.sql каталога dir, которые читает LoadDir.
+// Пустой DownSQL не записывается. Существующие файлы не перезаписываются
+func WriteSQLMigration(dir string, record MigrationRecord) error {
+	if !sqlMigrationFile.MatchString(record.Name + ".up.sql") {
+		return fmt.Errorf("migration name %s must have a numeric prefix, e.g. 001_%s", record.Name, record.Name)
+	}
+
+	files := []struct {
+		suffix, content string
+	}{
+		{"up", record.UpSQL},
+		{"down", record.DownSQL},
+	}
+
+	for _, file := range files {
+		if file.content == "" {
+			continue
+		}
+		fileName := record.Name + "." + file.suffix + ".sql"
+		f, err := os.OpenFile(filepath.Join(dir, fileName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to create migration file: %w", err)
+		}
+		if _, err := f.WriteString(file.content); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write migration file: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write migration file: %w", err)
+		}
+	}
+	return nil
+}
+
+// liveTableColumns - колонки таблицы в порядке их позиции и их типы
+type liveTableColumns struct {
+	names []string
+	types map[string]string
+}
+
+// liveColumns возвращает колонки таблицы из system.columns. Для
+// несуществующей таблицы список пуст
+func (m *Migrator) liveColumns(ctx context.Context, table string) (liveTableColumns, error) {
+	var rows []struct {
+		Name string `ch:"name"`
+		Type string `ch:"type"`
+	}
+
+	sql := "SELECT name, type FROM system.columns WHERE database = currentDatabase() AND table = ? ORDER BY position"
+	args := []interface{}{table}
+	if m.db.database != "" {
+		sql = "SELECT name, type FROM system.columns WHERE database = ? AND table = ? ORDER BY position"
+		args = []interface{}{m.db.database, table}
+	}
+	if err := m.db.Query(ctx, &rows, sql, args...); err != nil {
+		return liveTableColumns{}, fmt.Errorf("failed to get columns of %s: %w", table, err)
+	}
+
+	columns := liveTableColumns{types: make(map[string]string, len(rows))}
+	for _, row := range rows {
+		columns.names = append(columns.names, row.Name)
+		columns.types[row.Name] = row.Type
+	}
+	return columns, nil
+}
+
+// sameColumnType сравнивает типы без учета пробелов: system.columns
+// возвращает типы в нормализованном виде, например Decimal(10, 2)
+func sameColumnType(a, b string) bool {
+	return strings.ReplaceAll(a, " ", "") == strings.ReplaceAll(b, " ", "")
+}
+
+// hasStatements сообщает, есть ли среди строк скрипта запросы, а не только
+// комментарии
+func hasStatements(lines []string) bool {
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
+
+// joinStatements объединяет запросы в скрипт для ExecScript
+func joinStatements(statements []string) string {
+	if len(statements) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, statement := range statements {
+		b.WriteString(statement)
+		if !strings.HasPrefix(statement, "--") {
+			b.WriteString(";")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
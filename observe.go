@@ -0,0 +1,191 @@
+package chorm
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Operation представляет вид выполняемого запроса
+type Operation string
+
+const (
+	OperationSelect   Operation = "select"
+	OperationInsert   Operation = "insert"
+	OperationDDL      Operation = "ddl"
+	OperationMutation Operation = "mutation"
+	OperationOther    Operation = "other"
+)
+
+// maxLoggedSQLLength ограничивает длину SQL в журнале времени выполнения
+const maxLoggedSQLLength = 200
+
+// QueryEvent описывает выполнение одного запроса. Хуки могут изменить SQL и
+// Args в Before: выполняется запрос из события
+type QueryEvent struct {
+	Operation Operation
+	Table     string
+	SQL       string
+	Args      []interface{}
+	Start     time.Time
+	Duration  time.Duration // Заполняется перед After
+	Rows      int64         // Прочитанные строки для SELECT, затронутые строки для остальных запросов
+	Err       error         // Заполняется перед After
+}
+
+// readOnly сообщает, что запрос только читает данные и его можно повторить
+func (e *QueryEvent) readOnly() bool {
+	return e.Operation == OperationSelect
+}
+
+// Hook перехватывает выполнение запросов. Before вызываются в порядке
+// регистрации и могут вернуть новый контекст (например, со span трассировки),
+// After вызываются в обратном порядке после выполнения запроса
+type Hook interface {
+	Before(ctx context.Context, event *QueryEvent) context.Context
+	After(ctx context.Context, event *QueryEvent)
+}
+
+// Use добавляет хук, через который проходят все запросы DB, включая
+// транзакции, сессии, построитель запросов и мигратор. Хуки регистрируются
+// при инициализации, до начала выполнения запросов
+func (db *DB) Use(hook Hook) {
+	db.hooks = append(db.hooks, hook)
+}
+
+// beforeQuery создает событие запроса, фиксирует время начала и вызывает Before хуков
+func (db *DB) beforeQuery(ctx context.Context, query string, args []interface{}) (context.Context, *QueryEvent) {
+	event := &QueryEvent{
+		Operation: classifyOperation(query),
+		Table:     extractTable(query),
+		SQL:       query,
+		Args:      args,
+	}
+
+	for _, hook := range db.hooks {
+		ctx = hook.Before(ctx, event)
+	}
+
+	event.Start = time.Now()
+	return ctx, event
+}
+
+// finishQuery завершает событие запроса, журналирует время выполнения,
+// вызывает After хуков и возвращает классифицированную ошибку
+func (db *DB) finishQuery(ctx context.Context, event *QueryEvent, rows int64, err error) error {
+	event.Duration = time.Since(event.Start)
+	event.Rows = rows
+	event.Err = classifyError(err)
+
+	db.logQuery(ctx, event)
+	for i := len(db.hooks) - 1; i >= 0; i-- {
+		db.hooks[i].After(ctx, event)
+	}
+
+	return event.Err
+}
+
+// logQuery журналирует время выполнения запроса. Если задан SlowQueryThreshold,
+// журналируются только запросы, выполнявшиеся дольше порога, иначе в режиме
+// Debug журналируются все запросы. С Config.Slog запросы журналируются
+// записями с атрибутами chorm.* (уровень Warn для медленных, Debug для остальных)
+func (db *DB) logQuery(ctx context.Context, event *QueryEvent) {
+	if db.config.Slog != nil {
+		switch {
+		case db.config.SlowQueryThreshold > 0:
+			if event.Duration >= db.config.SlowQueryThreshold {
+				db.logQuerySlog(ctx, slog.LevelWarn, "slow query", event)
+			}
+		case db.config.Debug:
+			db.logQuerySlog(ctx, slog.LevelDebug, "query", event)
+		}
+		return
+	}
+
+	status := "ok"
+	if event.Err != nil {
+		status = "error: " + event.Err.Error()
+	}
+
+	switch {
+	case db.config.SlowQueryThreshold > 0:
+		if event.Duration >= db.config.SlowQueryThreshold {
+			db.warnf("slow query: %s, rows: %d, %s: %s",
+				event.Duration, event.Rows, status, truncateSQL(event.SQL))
+		}
+	case db.config.Debug:
+		db.debugf("Query done (%s): %s, rows: %d, %s: %s",
+			event.Operation, event.Duration, event.Rows, status, truncateSQL(event.SQL))
+	}
+}
+
+// truncateSQL схлопывает пробельные символы и обрезает SQL для журнала
+func truncateSQL(sql string) string {
+	return truncateText(sql, maxLoggedSQLLength)
+}
+
+// truncateText схлопывает пробельные символы и обрезает текст до limit символов
+func truncateText(text string, limit int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) > limit {
+		return string(runes[:limit]) + "…"
+	}
+	return text
+}
+
+// extractTable определяет основную таблицу запроса: после FROM для чтения,
+// INTO для вставки и TABLE/VIEW/DICTIONARY для DDL и мутаций
+func extractTable(query string) string {
+	fields := strings.Fields(query)
+	for i := 0; i < len(fields)-1; i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "FROM", "INTO", "TABLE", "VIEW", "DICTIONARY":
+			j := i + 1
+			// Пропускаем IF [NOT] EXISTS
+			for j < len(fields) && (strings.EqualFold(fields[j], "IF") || strings.EqualFold(fields[j], "NOT") || strings.EqualFold(fields[j], "EXISTS")) {
+				j++
+			}
+			if j >= len(fields) || strings.HasPrefix(fields[j], "(") {
+				// Подзапрос или табличная функция без имени
+				return ""
+			}
+			name := fields[j]
+			if k := strings.IndexAny(name, "(,;"); k >= 0 {
+				name = name[:k]
+			}
+			return strings.ReplaceAll(name, "`", "")
+		}
+	}
+	return ""
+}
+
+// classifyOperation определяет вид запроса по первому ключевому слову
+func classifyOperation(query string) Operation {
+	fields := strings.Fields(strings.ToUpper(strings.TrimLeft(query, " \t\n\r(")))
+	if len(fields) == 0 {
+		return OperationOther
+	}
+
+	switch fields[0] {
+	case "SELECT", "WITH", "SHOW", "DESCRIBE", "DESC", "EXISTS", "EXPLAIN", "WATCH":
+		return OperationSelect
+	case "INSERT":
+		return OperationInsert
+	case "DELETE", "UPDATE":
+		return OperationMutation
+	case "ALTER":
+		// ALTER TABLE t UPDATE/DELETE - мутации данных, остальные ALTER меняют схему
+		for _, f := range fields[1:] {
+			if f == "UPDATE" || f == "DELETE" {
+				return OperationMutation
+			}
+		}
+		return OperationDDL
+	case "CREATE", "DROP", "RENAME", "TRUNCATE", "ATTACH", "DETACH", "OPTIMIZE":
+		return OperationDDL
+	default:
+		return OperationOther
+	}
+}
@@ -0,0 +1,176 @@
+package chorm
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Option настраивает конфигурацию подключения для Connect. Config тоже
+// является Option: Connect(ctx, config) заменяет всю конфигурацию, а
+// последующие опции уточняют ее
+type Option interface {
+	apply(c *Config)
+}
+
+// optionFunc адаптирует функцию к интерфейсу Option
+type optionFunc func(c *Config)
+
+func (f optionFunc) apply(c *Config) {
+	f(c)
+}
+
+// apply реализует Option: конфигурация заменяется целиком
+func (c Config) apply(target *Config) {
+	*target = c
+}
+
+// newConfig собирает конфигурацию из опций в порядке их передачи
+func newConfig(opts []Option) Config {
+	var config Config
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(&config)
+		}
+	}
+	return config
+}
+
+// WithHost задает адрес сервера ClickHouse
+func WithHost(host string) Option {
+	return optionFunc(func(c *Config) {
+		c.Host = host
+	})
+}
+
+// WithPort задает порт сервера (по умолчанию зависит от протокола и TLS)
+func WithPort(port int) Option {
+	return optionFunc(func(c *Config) {
+		c.Port = port
+	})
+}
+
+// WithDatabase задает базу данных
+func WithDatabase(database string) Option {
+	return optionFunc(func(c *Config) {
+		c.Database = database
+	})
+}
+
+// WithAuth задает имя пользователя и пароль
+func WithAuth(username, password string) Option {
+	return optionFunc(func(c *Config) {
+		c.Username = username
+		c.Password = password
+	})
+}
+
+// WithPool задает параметры пула соединений
+func WithPool(maxOpen, maxIdle int, maxLifetime time.Duration) Option {
+	return optionFunc(func(c *Config) {
+		c.MaxOpenConns = maxOpen
+		c.MaxIdleConns = maxIdle
+		c.ConnMaxLifetime = maxLifetime
+	})
+}
+
+// WithTLS включает TLS. Пустые пути к файлам не изменяют ранее заданные
+func WithTLS(certFile, keyFile, caFile string) Option {
+	return optionFunc(func(c *Config) {
+		c.TLS = true
+		if certFile != "" {
+			c.TLSCertFile = certFile
+		}
+		if keyFile != "" {
+			c.TLSKeyFile = keyFile
+		}
+		if caFile != "" {
+			c.TLSCAFile = caFile
+		}
+	})
+}
+
+// WithProtocol задает протокол подключения
+func WithProtocol(protocol Protocol) Option {
+	return optionFunc(func(c *Config) {
+		c.Protocol = protocol
+	})
+}
+
+// WithCompression задает алгоритм и уровень сжатия (0 - уровень драйвера по умолчанию)
+func WithCompression(method CompressionMethod, level int) Option {
+	return optionFunc(func(c *Config) {
+		c.CompressionMethod = method
+		c.CompressionLevel = level
+	})
+}
+
+// WithTimeouts задает таймауты установки соединения, чтения и записи
+func WithTimeouts(dial, read, write time.Duration) Option {
+	return optionFunc(func(c *Config) {
+		c.DialTimeout = dial
+		c.ReadTimeout = read
+		c.WriteTimeout = write
+	})
+}
+
+// WithMaxExecutionTime ограничивает время выполнения запросов на сервере
+func WithMaxExecutionTime(d time.Duration) Option {
+	return optionFunc(func(c *Config) {
+		c.MaxExecutionTime = d
+	})
+}
+
+// WithSetting добавляет настройку ClickHouse, применяемую ко всем запросам
+func WithSetting(key string, value interface{}) Option {
+	return optionFunc(func(c *Config) {
+		settings := make(map[string]interface{}, len(c.Settings)+1)
+		for k, v := range c.Settings {
+			settings[k] = v
+		}
+		settings[key] = value
+		c.Settings = settings
+	})
+}
+
+// WithSessionTimeout задает время простоя, после которого сессия закрывается
+func WithSessionTimeout(d time.Duration) Option {
+	return optionFunc(func(c *Config) {
+		c.SessionTimeout = d
+	})
+}
+
+// WithKeepAlive включает проверку простаивающих соединений каждые interval
+func WithKeepAlive(interval time.Duration) Option {
+	return optionFunc(func(c *Config) {
+		c.KeepAliveInterval = interval
+	})
+}
+
+// WithDefaultEngine задает движок CreateTable для моделей без тега ch_engine
+func WithDefaultEngine(engine string) Option {
+	return optionFunc(func(c *Config) {
+		c.DefaultEngine = engine
+	})
+}
+
+// WithDebug включает журналирование запросов
+func WithDebug() Option {
+	return optionFunc(func(c *Config) {
+		c.Debug = true
+	})
+}
+
+// WithSlog журналирует через l: запросы - записями с атрибутами chorm.*,
+// остальные сообщения - текстом
+func WithSlog(l *slog.Logger) Option {
+	return optionFunc(func(c *Config) {
+		c.Slog = l
+	})
+}
+
+// WithSlowQueryThreshold журналирует только запросы, выполнявшиеся дольше порога
+func WithSlowQueryThreshold(d time.Duration) Option {
+	return optionFunc(func(c *Config) {
+		c.SlowQueryThreshold = d
+	})
+}
@@ -0,0 +1,287 @@
+// Package prometheus экспортирует метрики chorm в Prometheus. Пакет вынесен
+// в отдельный модуль, чтобы основной пакет chorm не зависел от
+// prometheus/client_golang, и подключается к DB и ClusterDB через хуки:
+//
+//	chormprom "github.com/AlanForester/chorm/prometheus"
+//
+//	err := chormprom.NewCollector().Observe(db).Register(prometheus.DefaultRegisterer)
+package prometheus
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/AlanForester/chorm"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector собирает метрики Prometheus по запросам (через хуки), пулам
+// соединений и здоровью узлов кластера
+type Collector struct {
+	queriesTotal    *prometheus.CounterVec
+	queryDuration   *prometheus.HistogramVec
+	rowsScanned     prometheus.Counter
+	insertRowsTotal prometheus.Counter
+
+	poolOpen         *prometheus.Desc
+	poolInUse        *prometheus.Desc
+	poolIdle         *prometheus.Desc
+	poolWaitCount    *prometheus.Desc
+	poolWaitDuration *prometheus.Desc
+	nodeHealthy      *prometheus.Desc
+	nodeOpen         *prometheus.Desc
+	nodeInUse        *prometheus.Desc
+	nodeIdle         *prometheus.Desc
+	nodePingLatency  *prometheus.Desc
+	nodeErrors       *prometheus.Desc
+
+	mu       sync.RWMutex
+	dbs      []*chorm.DB
+	clusters []*chorm.ClusterDB
+}
+
+// NewCollector создает сборщик метрик с префиксом chorm_
+func NewCollector() *Collector {
+	const namespace = "chorm"
+	poolLabels := []string{"host", "database"}
+	nodeLabels := []string{"cluster", "node"}
+
+	return &Collector{
+		queriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "queries_total",
+			Help:      "Number of executed statements by operation and status.",
+		}, []string{"operation", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "query_duration_seconds",
+			Help:      "Statement execution time by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		rowsScanned: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_scanned_total",
+			Help:      "Number of rows read by SELECT statements.",
+		}),
+		insertRowsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "insert_rows_total",
+			Help:      "Number of rows written by INSERT statements.",
+		}),
+
+		poolOpen: prometheus.NewDesc(namespace+"_pool_open_connections",
+			"Number of established connections, both in use and idle.", poolLabels, nil),
+		poolInUse: prometheus.NewDesc(namespace+"_pool_in_use_connections",
+			"Number of connections currently in use.", poolLabels, nil),
+		poolIdle: prometheus.NewDesc(namespace+"_pool_idle_connections",
+			"Number of idle connections.", poolLabels, nil),
+		poolWaitCount: prometheus.NewDesc(namespace+"_pool_wait_count_total",
+			"Total number of connections waited for.", poolLabels, nil),
+		poolWaitDuration: prometheus.NewDesc(namespace+"_pool_wait_duration_seconds_total",
+			"Total time blocked waiting for a new connection.", poolLabels, nil),
+		nodeHealthy: prometheus.NewDesc(namespace+"_cluster_node_healthy",
+			"Whether the cluster node passed the last health check (1) or not (0).", nodeLabels, nil),
+		nodeOpen: prometheus.NewDesc(namespace+"_cluster_node_open_connections",
+			"Number of established connections to the cluster node.", nodeLabels, nil),
+		nodeInUse: prometheus.NewDesc(namespace+"_cluster_node_in_use_connections",
+			"Number of connections to the cluster node currently in use.", nodeLabels, nil),
+		nodeIdle: prometheus.NewDesc(namespace+"_cluster_node_idle_connections",
+			"Number of idle connections to the cluster node.", nodeLabels, nil),
+		nodePingLatency: prometheus.NewDesc(namespace+"_cluster_node_ping_latency_seconds",
+			"Duration of the last health check ping of the cluster node.", nodeLabels, nil),
+		nodeErrors: prometheus.NewDesc(namespace+"_cluster_node_errors_total",
+			"Number of failed health checks and statements on the cluster node.", nodeLabels, nil),
+	}
+}
+
+// Observe подключает сборщик к DB: запросы учитываются через хуки,
+// статистика пула соединений собирается при каждом сборе метрик. Повторный
+// вызов для той же DB ничего не делает. DB, полученные UseDatabase, разделяют
+// пул исходной DB: наблюдайте только одну из них
+func (c *Collector) Observe(db *chorm.DB) *Collector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, observed := range c.dbs {
+		if observed == db {
+			return c
+		}
+	}
+
+	db.Use(c)
+	c.dbs = append(c.dbs, db)
+	return c
+}
+
+// ObserveCluster подключает сборщик к ClusterDB: запросы ко всем узлам
+// учитываются через хуки, здоровье и пулы соединений узлов экспортируются
+// из ClusterDB.Stats. Повторный вызов для той же ClusterDB ничего не делает
+func (c *Collector) ObserveCluster(cdb *chorm.ClusterDB) *Collector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, observed := range c.clusters {
+		if observed == cdb {
+			return c
+		}
+	}
+
+	cdb.Use(c)
+	c.clusters = append(c.clusters, cdb)
+	return c
+}
+
+// Register регистрирует сборщик в реестре Prometheus
+func (c *Collector) Register(registerer prometheus.Registerer) error {
+	if err := registerer.Register(c); err != nil {
+		return fmt.Errorf("failed to register metrics collector: %w", err)
+	}
+	return nil
+}
+
+// Before реализует chorm.Hook
+func (c *Collector) Before(ctx context.Context, event *chorm.QueryEvent) context.Context {
+	return ctx
+}
+
+// After реализует chorm.Hook и учитывает выполненный запрос
+func (c *Collector) After(ctx context.Context, event *chorm.QueryEvent) {
+	status := "ok"
+	if event.Err != nil {
+		status = "error"
+	}
+
+	operation := string(event.Operation)
+	c.queriesTotal.WithLabelValues(operation, status).Inc()
+	c.queryDuration.WithLabelValues(operation).Observe(event.Duration.Seconds())
+
+	if event.Err != nil || event.Rows <= 0 {
+		return
+	}
+	switch event.Operation {
+	case chorm.OperationSelect:
+		c.rowsScanned.Add(float64(event.Rows))
+	case chorm.OperationInsert:
+		c.insertRowsTotal.Add(float64(event.Rows))
+	}
+}
+
+// Describe реализует prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.queriesTotal.Describe(ch)
+	c.queryDuration.Describe(ch)
+	c.rowsScanned.Describe(ch)
+	c.insertRowsTotal.Describe(ch)
+
+	ch <- c.poolOpen
+	ch <- c.poolInUse
+	ch <- c.poolIdle
+	ch <- c.poolWaitCount
+	ch <- c.poolWaitDuration
+	ch <- c.nodeHealthy
+	ch <- c.nodeOpen
+	ch <- c.nodeInUse
+	ch <- c.nodeIdle
+	ch <- c.nodePingLatency
+	ch <- c.nodeErrors
+}
+
+// Collect реализует prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.queriesTotal.Collect(ch)
+	c.queryDuration.Collect(ch)
+	c.rowsScanned.Collect(ch)
+	c.insertRowsTotal.Collect(ch)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, pool := range c.poolStats() {
+		labels := pool.labels[:]
+		ch <- prometheus.MustNewConstMetric(c.poolOpen, prometheus.GaugeValue, float64(pool.stats.OpenConnections), labels...)
+		ch <- prometheus.MustNewConstMetric(c.poolInUse, prometheus.GaugeValue, float64(pool.stats.InUse), labels...)
+		ch <- prometheus.MustNewConstMetric(c.poolIdle, prometheus.GaugeValue, float64(pool.stats.Idle), labels...)
+		ch <- prometheus.MustNewConstMetric(c.poolWaitCount, prometheus.CounterValue, float64(pool.stats.WaitCount), labels...)
+		ch <- prometheus.MustNewConstMetric(c.poolWaitDuration, prometheus.CounterValue, pool.stats.WaitDuration.Seconds(), labels...)
+	}
+
+	for _, node := range c.nodeStats() {
+		labels := node.labels[:]
+		healthy := 0.0
+		if node.stats.Healthy {
+			healthy = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.nodeHealthy, prometheus.GaugeValue, healthy, labels...)
+		ch <- prometheus.MustNewConstMetric(c.nodeOpen, prometheus.GaugeValue, float64(node.stats.OpenConnections), labels...)
+		ch <- prometheus.MustNewConstMetric(c.nodeInUse, prometheus.GaugeValue, float64(node.stats.InUse), labels...)
+		ch <- prometheus.MustNewConstMetric(c.nodeIdle, prometheus.GaugeValue, float64(node.stats.Idle), labels...)
+		ch <- prometheus.MustNewConstMetric(c.nodePingLatency, prometheus.GaugeValue, node.stats.LastPingLatency.Seconds(), labels...)
+		ch <- prometheus.MustNewConstMetric(c.nodeErrors, prometheus.CounterValue, float64(node.stats.Errors), labels...)
+	}
+}
+
+// poolMetric - статистика пулов DB с одинаковыми метками host и database
+type poolMetric struct {
+	labels [2]string
+	stats  sql.DBStats
+}
+
+// poolStats суммирует статистику пулов наблюдаемых DB с одинаковыми метками,
+// чтобы не экспортировать повторяющиеся ряды
+func (c *Collector) poolStats() []*poolMetric {
+	var pools []*poolMetric
+	byLabels := make(map[[2]string]*poolMetric, len(c.dbs))
+	for _, db := range c.dbs {
+		labels := [2]string{db.Host(), db.Database()}
+		pool, ok := byLabels[labels]
+		if !ok {
+			pool = &poolMetric{labels: labels}
+			byLabels[labels] = pool
+			pools = append(pools, pool)
+		}
+
+		stats := db.Stats()
+		pool.stats.OpenConnections += stats.OpenConnections
+		pool.stats.InUse += stats.InUse
+		pool.stats.Idle += stats.Idle
+		pool.stats.WaitCount += stats.WaitCount
+		pool.stats.WaitDuration += stats.WaitDuration
+	}
+	return pools
+}
+
+// nodeMetric - статистика узла с одинаковыми метками cluster и node во всех
+// наблюдаемых ClusterDB
+type nodeMetric struct {
+	labels [2]string
+	stats  chorm.NodeStats
+}
+
+// nodeStats объединяет статистику узлов наблюдаемых ClusterDB с одинаковым
+// именем кластера: соединения и ошибки суммируются, узел считается здоровым,
+// если он прошел проверку в любой из них
+func (c *Collector) nodeStats() []*nodeMetric {
+	var nodes []*nodeMetric
+	byLabels := make(map[[2]string]*nodeMetric)
+	for _, cdb := range c.clusters {
+		for key, stats := range cdb.Stats() {
+			labels := [2]string{cdb.Name(), key}
+			node, ok := byLabels[labels]
+			if !ok {
+				node = &nodeMetric{labels: labels}
+				byLabels[labels] = node
+				nodes = append(nodes, node)
+			}
+
+			node.stats.Healthy = node.stats.Healthy || stats.Healthy
+			node.stats.OpenConnections += stats.OpenConnections
+			node.stats.InUse += stats.InUse
+			node.stats.Idle += stats.Idle
+			node.stats.Errors += stats.Errors
+			if stats.LastPingLatency > node.stats.LastPingLatency {
+				node.stats.LastPingLatency = stats.LastPingLatency
+			}
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,138 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AlanForester/chorm"
+	"github.com/AlanForester/chorm/chormtest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type testEvent struct {
+	ID uint32 `ch:"id" ch_type:"UInt32"`
+}
+
+func (e *testEvent) TableName() string {
+	return "events"
+}
+
+// gather собирает значения метрик реестра по ключу имя,метка=значение
+func gather(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			name := family.GetName()
+			for _, label := range metric.GetLabel() {
+				name += "," + label.GetName() + "=" + label.GetValue()
+			}
+			if _, ok := values[name]; ok {
+				t.Errorf("Duplicate series %s", name)
+			}
+			switch {
+			case metric.Counter != nil:
+				values[name] = metric.GetCounter().GetValue()
+			case metric.Gauge != nil:
+				values[name] = metric.GetGauge().GetValue()
+			case metric.Histogram != nil:
+				values[name] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return values
+}
+
+// TestCollector тестирует сбор метрик Prometheus
+func TestCollector(t *testing.T) {
+	ctx := context.Background()
+	db := chormtest.New()
+	defer db.Close()
+
+	cluster := chorm.NewCluster("analytics")
+	cluster.AddNode(&chorm.ClusterNode{Host: "node1", Port: 9000, Healthy: true})
+	cluster.AddNode(&chorm.ClusterNode{Host: "node2", Port: 9000})
+
+	registry := prometheus.NewRegistry()
+	collector := NewCollector().Observe(db.DB).Observe(db.DB).ObserveCluster(chorm.NewClusterDB(cluster, chorm.Config{}))
+	if err := collector.Register(registry); err != nil {
+		t.Fatalf("Failed to register collector: %v", err)
+	}
+
+	db.SetRows("FROM numbers", []map[string]interface{}{{"value": int64(1)}, {"value": int64(2)}})
+	var rows []map[string]interface{}
+	if err := db.Query(ctx, &rows, "SELECT value FROM numbers"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if err := db.InsertBatch(ctx, []interface{}{&testEvent{ID: 1}, &testEvent{ID: 2}, &testEvent{ID: 3}}); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	values := gather(t, registry)
+	expected := map[string]float64{
+		"chorm_queries_total,operation=select,status=ok":                        1,
+		"chorm_queries_total,operation=insert,status=ok":                        1,
+		"chorm_query_duration_seconds,operation=select":                         1,
+		"chorm_rows_scanned_total":                                              2,
+		"chorm_insert_rows_total":                                               3,
+		"chorm_pool_open_connections,database=fake,host=fake":                   1,
+		"chorm_cluster_node_healthy,cluster=analytics,node=node1:9000":          1,
+		"chorm_cluster_node_healthy,cluster=analytics,node=node2:9000":          0,
+		"chorm_cluster_node_open_connections,cluster=analytics,node=node1:9000": 0,
+		"chorm_cluster_node_errors_total,cluster=analytics,node=node2:9000":     0,
+	}
+	for name, value := range expected {
+		got, ok := values[name]
+		if !ok {
+			t.Errorf("Metric %s not found", name)
+		} else if got != value {
+			t.Errorf("Metric %s = %v, expected %v", name, got, value)
+		}
+	}
+}
+
+// TestCollectorSharedLabels тестирует объединение пулов DB и узлов ClusterDB
+// с одинаковыми метками
+func TestCollectorSharedLabels(t *testing.T) {
+	ctx := context.Background()
+	first, second := chormtest.New(), chormtest.New()
+	defer first.Close()
+	defer second.Close()
+
+	cluster := chorm.NewCluster("analytics")
+	cluster.AddNode(&chorm.ClusterNode{Host: "node1", Port: 9000, Healthy: true})
+
+	registry := prometheus.NewRegistry()
+	collector := NewCollector().
+		Observe(first.DB).
+		Observe(second.DB).
+		Observe(first.UseDatabase("logs")).
+		ObserveCluster(chorm.NewClusterDB(cluster, chorm.Config{})).
+		ObserveCluster(chorm.NewClusterDB(cluster, chorm.Config{}))
+	if err := collector.Register(registry); err != nil {
+		t.Fatalf("Failed to register collector: %v", err)
+	}
+
+	for _, db := range []*chormtest.FakeDB{first, second} {
+		if _, err := db.Exec(ctx, "SELECT 1"); err != nil {
+			t.Fatalf("Exec failed: %v", err)
+		}
+	}
+
+	values := gather(t, registry)
+	expected := map[string]float64{
+		"chorm_pool_open_connections,database=fake,host=fake":          2,
+		"chorm_pool_open_connections,database=logs,host=fake":          1,
+		"chorm_cluster_node_healthy,cluster=analytics,node=node1:9000": 1,
+	}
+	for name, value := range expected {
+		if got, ok := values[name]; !ok || got != value {
+			t.Errorf("Metric %s = %v, expected %v", name, got, value)
+		}
+	}
+}
@@ -2,24 +2,49 @@ package chorm
 
 import (
 	"context"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
 )
 
 // Query представляет построитель запросов
 type Query struct {
-	db       *DB
-	table    string
-	selects  []string
-	wheres   []string
-	groupBy  []string
-	orderBy  []string
-	limit    int
-	offset   int
-	args     []interface{}
-	distinct bool
-	having   []string
-	joins    []string
+	db          *DB
+	table       string
+	tableFunc   func() string
+	selects     []string
+	wheres      []string
+	groupBy     []string
+	groupMod    string // Модификатор GROUP BY: WITH ROLLUP или WITH CUBE
+	orderBy     []string
+	limit       int
+	offset      int
+	args        []interface{}
+	distinct    bool
+	having      []string
+	joins       []string
+	settings    map[string]interface{}
+	softDelete  *FieldInfo              // Поле ch_soft_delete модели из Model
+	updatedAt   *FieldInfo              // Поле ch_updated_at модели из Model
+	updatedBy   *FieldInfo              // Поле ch_updated_by модели из Model
+	withTrashed bool                    // Не исключать мягко удаленные записи
+	err         error                   // Ошибка построения, возвращается при выполнении
+	setOps      []setOperation          // EXCEPT и INTERSECT с другими запросами
+	partition   string                  // Выражение PARTITION после имени таблицы
+	final       bool                    // Модификатор FINAL
+	sample      string                  // Коэффициент SAMPLE
+	ctes        []commonTableExpression // Табличные выражения WITH
+	selectArgs  []interface{}           // Аргументы выражений SELECT
+	groupByArgs []interface{}           // Аргументы выражений GROUP BY
+	havingArgs  []interface{}           // Аргументы условий HAVING
+	quotaKey    string                  // Ключ квоты клиента из As
 }
 
 // NewQuery создает новый построитель запросов
@@ -31,33 +56,184 @@ func (db *DB) NewQuery() *Query {
 	}
 }
 
+// Clone возвращает независимую копию запроса. Изменение копии (условия,
+// сортировка, лимит) не влияет на исходный запрос, поэтому общую часть
+// можно строить один раз и ветвить:
+//
+//	base := db.NewQuery().Table("events").Where("user_id = ?", id)
+//	total, err := base.Clone().Count(ctx)
+//	err = base.Clone().OrderBy("created DESC").Limit(10).All(ctx, &events)
+func (q *Query) Clone() *Query {
+	clone := *q
+	clone.selects = append([]string(nil), q.selects...)
+	clone.wheres = append([]string(nil), q.wheres...)
+	clone.groupBy = append([]string(nil), q.groupBy...)
+	clone.orderBy = append([]string(nil), q.orderBy...)
+	clone.args = append([]interface{}(nil), q.args...)
+	clone.having = append([]string(nil), q.having...)
+	clone.joins = append([]string(nil), q.joins...)
+	clone.setOps = append([]setOperation(nil), q.setOps...)
+	clone.ctes = append([]commonTableExpression(nil), q.ctes...)
+	clone.selectArgs = append([]interface{}(nil), q.selectArgs...)
+	clone.groupByArgs = append([]interface{}(nil), q.groupByArgs...)
+	clone.havingArgs = append([]interface{}(nil), q.havingArgs...)
+	if q.settings != nil {
+		clone.settings = make(map[string]interface{}, len(q.settings))
+		for k, v := range q.settings {
+			clone.settings[k] = v
+		}
+	}
+	return &clone
+}
+
 // Table устанавливает таблицу для запроса
 func (q *Query) Table(table string) *Query {
 	q.table = table
+	q.tableFunc = nil
+	return q
+}
+
+// TableFunc устанавливает функцию, которая вычисляет имя таблицы при каждом
+// выполнении запроса (например, для таблиц с суффиксом даты)
+func (q *Query) TableFunc(fn func() string) *Query {
+	q.tableFunc = fn
 	return q
 }
 
+// TableSuffix устанавливает таблицу вида <base>_<t в формате layout>,
+// например events_20240101 для layout "20060102"
+func (q *Query) TableSuffix(base string, t time.Time, layout string) *Query {
+	return q.Table(TableSuffix(base, t, layout))
+}
+
+// TableSuffix возвращает имя таблицы вида <base>_<t в формате layout>.
+// Может использоваться в TableName модели для вставки в таблицу по дате записи
+func TableSuffix(base string, t time.Time, layout string) string {
+	return base + "_" + t.Format(layout)
+}
+
+// tableName возвращает имя таблицы запроса на момент выполнения
+func (q *Query) tableName() string {
+	if q.tableFunc != nil {
+		return q.db.qualifyTable(q.tableFunc())
+	}
+	return q.db.qualifyTable(q.table)
+}
+
 // Select устанавливает поля для выборки
 func (q *Query) Select(fields ...string) *Query {
 	if len(fields) > 0 {
 		q.selects = fields
+		q.selectArgs = nil
 	}
 	return q
 }
 
+// Partition ограничивает чтение партицией expr: после имени таблицы во FROM
+// добавляется PARTITION expr (поддерживается некоторыми табличными функциями и
+// системными таблицами). В отличие от условий WHERE и PREWHERE по ключу
+// партиционирования, выражение передается как есть
+func (q *Query) Partition(expr string) *Query {
+	q.partition = expr
+	return q
+}
+
+// Final добавляет модификатор FINAL: строки таблиц семейства
+// ReplacingMergeTree и CollapsingMergeTree схлопываются при чтении
+func (q *Query) Final() *Query {
+	q.final = true
+	return q
+}
+
 // Distinct добавляет DISTINCT к запросу
 func (q *Query) Distinct() *Query {
 	q.distinct = true
 	return q
 }
 
-// Where добавляет условие WHERE
+// Where добавляет условие WHERE. Аргумент-срез раскрывается в список
+// значений: Where("id IN (?)", ids)
 func (q *Query) Where(condition string, args ...interface{}) *Query {
+	condition, args = expandSliceArgs(condition, args)
 	q.wheres = append(q.wheres, condition)
 	q.args = append(q.args, args...)
 	return q
 }
 
+// expandSliceArgs раскрывает аргументы-срезы: ? заменяется на ?, ?, ? по
+// числу элементов, а элементы подставляются в аргументы, как In в sqlx.
+// Пустой срез заменяется на NULL, поэтому IN (?) с пустым срезом ложно.
+// []byte и значения driver.Valuer не раскрываются
+func expandSliceArgs(condition string, args []interface{}) (string, []interface{}) {
+	if !hasSliceArg(args) {
+		return condition, args
+	}
+
+	var b strings.Builder
+	expanded := make([]interface{}, 0, len(args))
+	n := 0
+	var quote byte
+	for i := 0; i < len(condition); i++ {
+		c := condition[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(condition) {
+				b.WriteByte(c)
+				i++
+				c = condition[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?' && n < len(args):
+			arg := args[n]
+			n++
+			if !isSliceArg(arg) {
+				expanded = append(expanded, arg)
+				break
+			}
+
+			rv := reflect.ValueOf(arg)
+			if rv.Len() == 0 {
+				b.WriteString("NULL")
+				continue
+			}
+			for j := 0; j < rv.Len(); j++ {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteByte('?')
+				expanded = append(expanded, rv.Index(j).Interface())
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String(), append(expanded, args[n:]...)
+}
+
+// hasSliceArg проверяет, есть ли среди аргументов срез для раскрытия
+func hasSliceArg(args []interface{}) bool {
+	for _, arg := range args {
+		if isSliceArg(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSliceArg проверяет, нужно ли раскрыть аргумент в список значений
+func isSliceArg(arg interface{}) bool {
+	switch arg.(type) {
+	case nil, []byte, driver.Valuer:
+		return false
+	}
+	kind := reflect.TypeOf(arg).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
 // WhereIn добавляет условие WHERE IN
 func (q *Query) WhereIn(field string, values []interface{}) *Query {
 	if len(values) == 0 {
@@ -108,6 +284,55 @@ func (q *Query) WhereLike(field, pattern string) *Query {
 	return q
 }
 
+// WhereILike добавляет условие без учета регистра через функцию ilike
+func (q *Query) WhereILike(field, pattern string) *Query {
+	condition := fmt.Sprintf("ilike(%s, ?)", field)
+	q.wheres = append(q.wheres, condition)
+	q.args = append(q.args, pattern)
+	return q
+}
+
+// WhereMatch добавляет условие по регулярному выражению (синтаксис re2) через функцию match
+func (q *Query) WhereMatch(field, regex string) *Query {
+	condition := fmt.Sprintf("match(%s, ?)", field)
+	q.wheres = append(q.wheres, condition)
+	q.args = append(q.args, regex)
+	return q
+}
+
+// WhereArrayHas добавляет условие, что массив field содержит value
+func (q *Query) WhereArrayHas(field string, value interface{}) *Query {
+	condition := fmt.Sprintf("has(%s, ?)", field)
+	q.wheres = append(q.wheres, condition)
+	q.args = append(q.args, value)
+	return q
+}
+
+// WhereArrayHasAll добавляет условие, что массив field содержит все values.
+// Пустой values соответствует любому массиву
+func (q *Query) WhereArrayHasAll(field string, values []interface{}) *Query {
+	return q.whereArrayFunc("hasAll", field, values)
+}
+
+// WhereArrayHasAny добавляет условие, что массив field содержит хотя бы одно
+// из values. Пустой values не соответствует ни одному массиву
+func (q *Query) WhereArrayHasAny(field string, values []interface{}) *Query {
+	return q.whereArrayFunc("hasAny", field, values)
+}
+
+// whereArrayFunc добавляет условие fn(field, [?, ...]) с массивом из values
+func (q *Query) whereArrayFunc(fn, field string, values []interface{}) *Query {
+	placeholders := make([]string, len(values))
+	for i := range values {
+		placeholders[i] = "?"
+	}
+
+	condition := fmt.Sprintf("%s(%s, [%s])", fn, field, strings.Join(placeholders, ", "))
+	q.wheres = append(q.wheres, condition)
+	q.args = append(q.args, values...)
+	return q
+}
+
 // WhereNull добавляет условие WHERE IS NULL
 func (q *Query) WhereNull(field string) *Query {
 	condition := fmt.Sprintf("%s IS NULL", field)
@@ -152,10 +377,52 @@ func (q *Query) GroupBy(fields ...string) *Query {
 	return q
 }
 
+// GroupByExpr добавляет в GROUP BY выражение с аргументами, например
+// toStartOfInterval(created, INTERVAL ? MINUTE). Аргументы подставляются
+// после аргументов WHERE и перед аргументами HAVING независимо от порядка
+// вызовов:
+//
+//	db.NewQuery().Table("events").
+//		Select("toStartOfHour(created) AS hour", "count() AS n").
+//		Where("created >= ?", since).
+//		GroupByExpr("toStartOfHour(created)")
+func (q *Query) GroupByExpr(expr string, args ...interface{}) *Query {
+	expr, args = expandSliceArgs(expr, args)
+	q.groupBy = append(q.groupBy, expr)
+	q.groupByArgs = append(q.groupByArgs, args...)
+	return q
+}
+
+// WithRollup добавляет к GROUP BY модификатор WITH ROLLUP: промежуточные
+// итоги по префиксам ключей группировки. Требует GroupBy
+func (q *Query) WithRollup() *Query {
+	q.groupMod = "WITH ROLLUP"
+	return q
+}
+
+// WithCube добавляет к GROUP BY модификатор WITH CUBE: итоги по всем
+// комбинациям ключей группировки. Требует GroupBy
+func (q *Query) WithCube() *Query {
+	q.groupMod = "WITH CUBE"
+	return q
+}
+
+// validate проверяет согласованность частей запроса перед выполнением
+func (q *Query) validate() error {
+	if q.err != nil {
+		return q.err
+	}
+	if q.groupMod != "" && len(q.groupBy) == 0 {
+		return fmt.Errorf("%s requires GROUP BY", q.groupMod)
+	}
+	return nil
+}
+
 // Having добавляет HAVING
 func (q *Query) Having(condition string, args ...interface{}) *Query {
+	condition, args = expandSliceArgs(condition, args)
 	q.having = append(q.having, condition)
-	q.args = append(q.args, args...)
+	q.havingArgs = append(q.havingArgs, args...)
 	return q
 }
 
@@ -181,6 +448,47 @@ func (q *Query) OrderByDesc(field string) *Query {
 	return q
 }
 
+// Положение NULL при сортировке
+const (
+	NullsFirst = "FIRST"
+	NullsLast  = "LAST"
+)
+
+// OrderSpec описывает одну колонку сортировки
+type OrderSpec struct {
+	Field     string
+	Direction string // ASC (по умолчанию) или DESC
+	Nulls     string // NullsFirst, NullsLast или пусто (поведение ClickHouse по умолчанию)
+}
+
+// String возвращает выражение сортировки, например "col ASC NULLS LAST"
+func (s OrderSpec) String() string {
+	dir := "ASC"
+	if s.Direction != "" {
+		dir = strings.ToUpper(s.Direction)
+	}
+
+	expr := fmt.Sprintf("%s %s", s.Field, dir)
+	if s.Nulls != "" {
+		nulls := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s.Nulls)), "NULLS ")
+		expr += " NULLS " + nulls
+	}
+	return expr
+}
+
+// OrderByNulls добавляет ORDER BY с указанием положения NULL (NullsFirst или NullsLast)
+func (q *Query) OrderByNulls(field, direction, nulls string) *Query {
+	return q.OrderBySpec(OrderSpec{Field: field, Direction: direction, Nulls: nulls})
+}
+
+// OrderBySpec добавляет в ORDER BY несколько колонок с собственными направлениями
+func (q *Query) OrderBySpec(specs ...OrderSpec) *Query {
+	for _, spec := range specs {
+		q.orderBy = append(q.orderBy, spec.String())
+	}
+	return q
+}
+
 // Limit устанавливает LIMIT
 func (q *Query) Limit(limit int) *Query {
 	q.limit = limit
@@ -193,10 +501,110 @@ func (q *Query) Offset(offset int) *Query {
 	return q
 }
 
+// Setting устанавливает настройку ClickHouse только для этого запроса (SETTINGS).
+// Настройки запроса переопределяют Config.Settings соединения
+func (q *Query) Setting(key string, value interface{}) *Query {
+	if q.settings == nil {
+		q.settings = make(map[string]interface{})
+	}
+	q.settings[key] = value
+	return q
+}
+
+// Settings устанавливает несколько настроек для этого запроса
+func (q *Query) Settings(settings map[string]interface{}) *Query {
+	for k, v := range settings {
+		q.Setting(k, v)
+	}
+	return q
+}
+
+// MaxExecutionTime ограничивает время выполнения этого запроса на сервере,
+// переопределяя Config.MaxExecutionTime (округляется вверх до секунд)
+func (q *Query) MaxExecutionTime(d time.Duration) *Query {
+	return q.Setting("max_execution_time", durationSeconds(d))
+}
+
+// As относит запрос к квоте клиента user: ключ квоты передается драйверу в
+// информации о клиенте (quota_key не является настройкой сервера) и попадает в
+// колонку quota_key system.query_log. Это атрибуция нагрузки, а не
+// аутентификация: запрос выполняется от пользователя соединения
+func (q *Query) As(user string) *Query {
+	q.quotaKey = user
+	return q
+}
+
+// context возвращает контекст выполнения запроса с ключом квоты из As
+func (q *Query) context(ctx context.Context) context.Context {
+	if q.quotaKey == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, quotaKeyKey{}, q.quotaKey)
+	return clickhouse.Context(ctx, clickhouse.WithQuotaKey(q.quotaKey))
+}
+
+// quotaKeyKey - ключ контекста с ключом квоты As для запросов к HTTP
+// интерфейсу в обход драйвера
+type quotaKeyKey struct{}
+
+// Profile применяет к запросу профиль настроек name через настройку profile.
+// Ограничения профиля (max_threads, max_memory_usage и т.п.) действуют только
+// на этот запрос
+func (q *Query) Profile(name string) *Query {
+	return q.Setting("profile", name)
+}
+
+// buildSettings строит SETTINGS clause из настроек запроса
+func (q *Query) buildSettings() string {
+	if len(q.settings) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(q.settings))
+	for k := range q.settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	settings := make([]string, len(keys))
+	for i, k := range keys {
+		settings[i] = fmt.Sprintf("%s = %s", k, formatSettingValue(q.settings[k]))
+	}
+
+	return "SETTINGS " + strings.Join(settings, ", ")
+}
+
+// ToSQL возвращает SQL запроса и аргументы без выполнения
+func (q *Query) ToSQL() (string, []interface{}) {
+	return q.buildSQL(), q.queryArgs()
+}
+
+// String возвращает SQL с подставленными аргументами для журналов и отладки.
+// Результат не предназначен для выполнения: значения SensitiveValue заменены
+// на ***, а подстановка выполняется на стороне клиента
+func (q *Query) String() string {
+	sql, args := q.buildQuery(), q.queryArgs()
+	interpolated, err := interpolateArgs(sql, args)
+	if err != nil {
+		return fmt.Sprintf("%s -- args: %v", sql, args)
+	}
+	return interpolated
+}
+
 // buildSQL строит SQL запрос
 func (q *Query) buildSQL() string {
+	return q.rebind(q.buildQuery())
+}
+
+// buildQuery строит SQL запрос с плейсхолдерами ?
+func (q *Query) buildQuery() string {
 	var parts []string
 
+	// WITH
+	if len(q.ctes) > 0 {
+		parts = append(parts, q.buildWith())
+	}
+
 	// SELECT
 	selectClause := "SELECT "
 	if q.distinct {
@@ -206,8 +614,18 @@ func (q *Query) buildSQL() string {
 	parts = append(parts, selectClause)
 
 	// FROM
-	if q.table != "" {
-		parts = append(parts, fmt.Sprintf("FROM %s", q.table))
+	if table := q.tableName(); table != "" {
+		from := "FROM " + table
+		if q.partition != "" {
+			from += " PARTITION " + q.partition
+		}
+		if q.final {
+			from += " FINAL"
+		}
+		if q.sample != "" {
+			from += " SAMPLE " + q.sample
+		}
+		parts = append(parts, from)
 	}
 
 	// JOIN
@@ -216,13 +634,16 @@ func (q *Query) buildSQL() string {
 	}
 
 	// WHERE
-	if len(q.wheres) > 0 {
-		parts = append(parts, fmt.Sprintf("WHERE %s", strings.Join(q.wheres, " AND ")))
+	if wheres := q.whereConditions(); len(wheres) > 0 {
+		parts = append(parts, fmt.Sprintf("WHERE %s", strings.Join(wheres, " AND ")))
 	}
 
 	// GROUP BY
 	if len(q.groupBy) > 0 {
 		parts = append(parts, fmt.Sprintf("GROUP BY %s", strings.Join(q.groupBy, ", ")))
+		if q.groupMod != "" {
+			parts = append(parts, q.groupMod)
+		}
 	}
 
 	// HAVING
@@ -245,70 +666,316 @@ func (q *Query) buildSQL() string {
 		parts = append(parts, fmt.Sprintf("OFFSET %d", q.offset))
 	}
 
+	// EXCEPT, INTERSECT
+	if len(q.setOps) > 0 {
+		parts = append(parts, q.buildSetOperations())
+	}
+
+	// SETTINGS
+	if settings := q.buildSettings(); settings != "" {
+		parts = append(parts, settings)
+	}
+
 	return strings.Join(parts, " ")
 }
 
+// rebind заменяет позиционные ? на стиль плейсхолдеров из конфигурации
+func (q *Query) rebind(sql string) string {
+	if q.db == nil {
+		return sql
+	}
+	return rebind(q.db.config.Placeholder, sql)
+}
+
+// countPlaceholders считает плейсхолдеры ? вне строковых литералов и
+// идентификаторов
+func countPlaceholders(sql string) int {
+	n := 0
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+		}
+	}
+	return n
+}
+
+// rebind заменяет ? вне строковых литералов и идентификаторов на плейсхолдеры
+// заданного стиля с последовательной нумерацией
+func rebind(style PlaceholderStyle, sql string) string {
+	if style == "" || style == PlaceholderQuestion {
+		return sql
+	}
+
+	var b strings.Builder
+	b.Grow(len(sql) + 8)
+
+	n := 0
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(sql) {
+				b.WriteByte(c)
+				i++
+				c = sql[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString(string(style))
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
 // Get выполняет запрос и возвращает одну запись
 func (q *Query) Get(ctx context.Context, result interface{}) error {
+	if err := q.validate(); err != nil {
+		return err
+	}
 	q.limit = 1
 	sql := q.buildSQL()
 
-	if q.db.config.Debug {
-		fmt.Printf("Get SQL: %s\n", sql)
-		fmt.Printf("Args: %v\n", q.args)
-	}
+	args := q.queryArgs()
 
-	return q.db.QueryRow(ctx, result, sql, q.args...)
+	q.db.debugf("Get SQL: %s", sql)
+	q.db.debugf("Get Args: %v", args)
+
+	return q.db.QueryRow(q.context(ctx), result, sql, args...)
 }
 
 // All выполняет запрос и возвращает все записи
 func (q *Query) All(ctx context.Context, result interface{}) error {
+	if err := q.validate(); err != nil {
+		return err
+	}
 	sql := q.buildSQL()
 
-	if q.db.config.Debug {
-		fmt.Printf("All SQL: %s\n", sql)
-		fmt.Printf("Args: %v\n", q.args)
+	args := q.queryArgs()
+
+	q.db.debugf("All SQL: %s", sql)
+	q.db.debugf("All Args: %v", args)
+
+	return q.db.Query(q.context(ctx), result, sql, args...)
+}
+
+// AllAsMap выполняет запрос и записывает строки в map dest (указатель на
+// map[K]V), индексируя их значением колонки keyColumn. V - структура или
+// map[string]interface{}; значение ключа приводится к K. При повторяющихся ключах сохраняется последняя строка:
+//
+//	var users map[uint32]User
+//	err := db.NewQuery().Table("users").AllAsMap(ctx, "id", &users)
+func (q *Query) AllAsMap(ctx context.Context, keyColumn string, dest interface{}) error {
+	destVal := reflect.ValueOf(dest)
+	if destVal.Kind() != reflect.Ptr || destVal.Elem().Kind() != reflect.Map {
+		return fmt.Errorf("dest must be a pointer to map")
+	}
+	mapType := destVal.Elem().Type()
+	keyType, valueType := mapType.Key(), mapType.Elem()
+
+	// keyOf возвращает значение ключевой колонки строки
+	var keyOf func(row reflect.Value) reflect.Value
+	switch {
+	case valueType.Kind() == reflect.Map && valueType.Key().Kind() == reflect.String:
+		keyOf = func(row reflect.Value) reflect.Value {
+			return row.MapIndex(reflect.ValueOf(keyColumn).Convert(valueType.Key()))
+		}
+	case valueType.Kind() == reflect.Struct:
+		info, err := NewMapper().ParseStruct(reflect.New(valueType).Interface())
+		if err != nil {
+			return fmt.Errorf("failed to parse struct: %w", err)
+		}
+		fieldName := ""
+		for _, field := range info.Fields {
+			if field.Name == keyColumn {
+				fieldName = field.FieldName
+				break
+			}
+		}
+		if fieldName == "" {
+			return fmt.Errorf("key column %s is not mapped to a field of %s", keyColumn, valueType)
+		}
+		keyOf = func(row reflect.Value) reflect.Value {
+			return row.FieldByName(fieldName)
+		}
+	default:
+		return fmt.Errorf("unsupported map value type %s", valueType)
+	}
+
+	rows := reflect.New(reflect.SliceOf(valueType))
+	if err := q.All(ctx, rows.Interface()); err != nil {
+		return err
 	}
 
-	return q.db.Query(ctx, result, sql, q.args...)
+	result := reflect.MakeMapWithSize(mapType, rows.Elem().Len())
+	for i := 0; i < rows.Elem().Len(); i++ {
+		row := rows.Elem().Index(i)
+		key := keyOf(row)
+		if key.IsValid() && key.Kind() == reflect.Interface {
+			key = key.Elem()
+		}
+		if !key.IsValid() {
+			return fmt.Errorf("row %d has no value in key column %s", i, keyColumn)
+		}
+		if !key.Type().ConvertibleTo(keyType) {
+			return fmt.Errorf("cannot use %s value of column %s as %s key", key.Type(), keyColumn, keyType)
+		}
+		result.SetMapIndex(key.Convert(keyType), row)
+	}
+	destVal.Elem().Set(result)
+	return nil
 }
 
 // Count выполняет запрос COUNT
 func (q *Query) Count(ctx context.Context) (int64, error) {
-	// Сохраняем оригинальные selects
-	originalSelects := q.selects
-	q.selects = []string{"COUNT(*)"}
+	if err := q.validate(); err != nil {
+		return 0, err
+	}
 
-	sql := q.buildSQL()
+	var sql string
+	var args []interface{}
+	if len(q.setOps) > 0 {
+		// Результат EXCEPT/INTERSECT считается подзапросом
+		sql = q.rebind(fmt.Sprintf("SELECT COUNT(*) FROM (%s)", q.buildQuery()))
+		args = q.queryArgs()
+	} else {
+		// Сохраняем оригинальные selects
+		originalSelects, originalSelectArgs := q.selects, q.selectArgs
+		q.selects, q.selectArgs = []string{"COUNT(*)"}, nil
 
-	if q.db.config.Debug {
-		fmt.Printf("Count SQL: %s\n", sql)
-		fmt.Printf("Args: %v\n", q.args)
+		sql = q.buildSQL()
+		args = q.queryArgs()
+
+		// Восстанавливаем оригинальные selects
+		q.selects, q.selectArgs = originalSelects, originalSelectArgs
 	}
 
+	q.db.debugf("Count SQL: %s", sql)
+	q.db.debugf("Count Args: %v", args)
+
 	var count int64
-	err := q.db.QueryRow(ctx, &count, sql, q.args...)
+	err := q.db.QueryRow(q.context(ctx), &count, sql, args...)
 
-	// Восстанавливаем оригинальные selects
-	q.selects = originalSelects
+	return count, err
+}
+
+// CountEstimateSampleRatio - доля данных, читаемая CountEstimate при наличии условий
+const CountEstimateSampleRatio = 0.1
+
+// CountEstimate возвращает приблизительное количество записей без полного чтения таблицы.
+//
+// Без условий (WHERE, JOIN, GROUP BY, HAVING) количество берется из метаданных
+// активных кусков в system.parts. Оценка мгновенная, но включает строки, еще не
+// схлопнутые слиянием (ReplacingMergeTree, CollapsingMergeTree) и удаленные
+// легковесным DELETE, поэтому может быть завышена.
+//
+// С условиями выполняется COUNT по выборке SAMPLE CountEstimateSampleRatio,
+// масштабированный через _sample_factor. Погрешность зависит от распределения
+// ключа сэмплирования. Если таблица не поддерживает SAMPLE, выполняется точный Count
+func (q *Query) CountEstimate(ctx context.Context) (int64, error) {
+	if q.estimateFromParts() {
+		database, table := "", q.tableName()
+		if i := strings.Index(table, "."); i >= 0 {
+			database, table = table[:i], table[i+1:]
+		}
+		database = strings.Trim(database, "`")
+		table = strings.Trim(table, "`")
+
+		sql := "SELECT toInt64(sum(rows)) FROM system.parts WHERE active AND database = currentDatabase() AND table = ?"
+		args := []interface{}{table}
+		if database != "" {
+			sql = "SELECT toInt64(sum(rows)) FROM system.parts WHERE active AND database = ? AND table = ?"
+			args = []interface{}{database, table}
+		}
+		sql = q.rebind(sql)
+
+		q.db.debugf("CountEstimate SQL: %s", sql)
+		q.db.debugf("CountEstimate Args: %v", args)
+
+		var count int64
+		err := q.db.QueryRow(q.context(ctx), &count, sql, args...)
+		return count, err
+	}
+
+	sql, args := q.buildSampleCountSQL()
+
+	q.db.debugf("CountEstimate SQL: %s", sql)
+	q.db.debugf("CountEstimate Args: %v", args)
+
+	var count int64
+	err := q.db.QueryRow(q.context(ctx), &count, sql, args...)
+	if err != nil && strings.Contains(err.Error(), "SAMPLING_NOT_SUPPORTED") {
+		return q.Count(ctx)
+	}
 
 	return count, err
 }
 
+// estimateFromParts сообщает, можно ли оценить количество по system.parts
+func (q *Query) estimateFromParts() bool {
+	return len(q.whereConditions()) == 0 && len(q.joins) == 0 && len(q.groupBy) == 0 && len(q.having) == 0
+}
+
+// buildSampleCountSQL строит COUNT по выборке с масштабированием и его аргументы
+func (q *Query) buildSampleCountSQL() (string, []interface{}) {
+	// Сохраняем оригинальные значения
+	originalSample, originalSelects, originalSelectArgs := q.sample, q.selects, q.selectArgs
+	originalOrderBy, originalLimit, originalOffset := q.orderBy, q.limit, q.offset
+
+	q.sample = strconv.FormatFloat(CountEstimateSampleRatio, 'f', -1, 64)
+	q.selects, q.selectArgs = []string{"toInt64(round(count() * any(_sample_factor)))"}, nil
+	q.orderBy, q.limit, q.offset = nil, 0, 0
+
+	sql, args := q.buildSQL(), q.queryArgs()
+
+	// Восстанавливаем оригинальные значения
+	q.sample, q.selects, q.selectArgs = originalSample, originalSelects, originalSelectArgs
+	q.orderBy, q.limit, q.offset = originalOrderBy, originalLimit, originalOffset
+
+	return sql, args
+}
+
 // Exists проверяет существование записей
 func (q *Query) Exists(ctx context.Context) (bool, error) {
-	q.selects = []string{"1"}
-	q.limit = 1
-
-	sql := q.buildSQL()
+	var sql string
+	if len(q.setOps) > 0 {
+		// Результат EXCEPT/INTERSECT проверяется подзапросом
+		sql = q.rebind(fmt.Sprintf("SELECT 1 FROM (%s) LIMIT 1", q.buildQuery()))
+	} else {
+		q.selects, q.selectArgs = []string{"1"}, nil
+		q.limit = 1
 
-	if q.db.config.Debug {
-		fmt.Printf("Exists SQL: %s\n", sql)
-		fmt.Printf("Args: %v\n", q.args)
+		sql = q.buildSQL()
 	}
+	args := q.queryArgs()
+
+	q.db.debugf("Exists SQL: %s", sql)
+	q.db.debugf("Exists Args: %v", args)
 
 	var exists int
-	err := q.db.QueryRow(ctx, &exists, sql, q.args...)
+	err := q.db.QueryRow(q.context(ctx), &exists, sql, args...)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
 
 	return err == nil, err
 }
@@ -373,6 +1040,45 @@ func (q *Query) Paginate(ctx context.Context, page, perPage int, result interfac
 	return total, err
 }
 
+// ForEachBatch читает результат запроса страницами LIMIT batchSize OFFSET n
+// и вызывает fn для каждой непустой страницы, пока страница не окажется
+// короче batchSize. Заданные Offset и Limit задают начало и общее число
+// строк и восстанавливаются после завершения. Пагинация по OFFSET проста, но
+// замедляется на больших смещениях; для стабильных страниц задайте OrderBy
+func (q *Query) ForEachBatch(ctx context.Context, batchSize int, fn func(rows []map[string]interface{}) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+
+	// Восстанавливаем оригинальные limit и offset после завершения
+	originalLimit, originalOffset := q.limit, q.offset
+	defer func() {
+		q.limit, q.offset = originalLimit, originalOffset
+	}()
+
+	for read := 0; originalLimit <= 0 || read < originalLimit; {
+		q.limit, q.offset = batchSize, originalOffset+read
+		if originalLimit > 0 && originalLimit-read < batchSize {
+			q.limit = originalLimit - read
+		}
+
+		var rows []map[string]interface{}
+		if err := q.All(ctx, &rows); err != nil {
+			return fmt.Errorf("failed to fetch batch at offset %d: %w", q.offset, err)
+		}
+		if len(rows) > 0 {
+			if err := fn(rows); err != nil {
+				return err
+			}
+		}
+		if len(rows) < q.limit {
+			break
+		}
+		read += len(rows)
+	}
+	return nil
+}
+
 // Update выполняет UPDATE запрос
 func (q *Query) Update(ctx context.Context, data map[string]interface{}) (Result, error) {
 	if len(data) == 0 {
@@ -390,32 +1096,38 @@ func (q *Query) Update(ctx context.Context, data map[string]interface{}) (Result
 	// Добавляем аргументы WHERE
 	args = append(args, q.args...)
 
-	sql := fmt.Sprintf("UPDATE %s SET %s", q.table, strings.Join(sets, ", "))
+	sql := fmt.Sprintf("UPDATE %s SET %s", q.tableName(), strings.Join(sets, ", "))
 
-	if len(q.wheres) > 0 {
-		sql += fmt.Sprintf(" WHERE %s", strings.Join(q.wheres, " AND "))
+	if wheres := q.whereConditions(); len(wheres) > 0 {
+		sql += fmt.Sprintf(" WHERE %s", strings.Join(wheres, " AND "))
 	}
+	sql = q.rebind(sql)
 
-	if q.db.config.Debug {
-		fmt.Printf("Update SQL: %s\n", sql)
-		fmt.Printf("Args: %v\n", args)
-	}
+	q.db.debugf("Update SQL: %s", sql)
+	q.db.debugf("Update Args: %v", args)
 
-	return q.db.Exec(ctx, sql, args...)
+	return q.db.Exec(q.context(ctx), sql, args...)
 }
 
-// Delete выполняет DELETE запрос
+// Delete выполняет DELETE запрос. Для модели с ch_soft_delete (Model) записи
+// не удаляются, а помечаются: колонке присваивается now()
 func (q *Query) Delete(ctx context.Context) (Result, error) {
-	sql := fmt.Sprintf("DELETE FROM %s", q.table)
+	if err := q.validate(); err != nil {
+		return Result{}, err
+	}
+	if q.softDelete != nil {
+		return q.softDeleteRows(ctx)
+	}
+
+	sql := fmt.Sprintf("DELETE FROM %s", q.tableName())
 
 	if len(q.wheres) > 0 {
 		sql += fmt.Sprintf(" WHERE %s", strings.Join(q.wheres, " AND "))
 	}
+	sql = q.rebind(sql)
 
-	if q.db.config.Debug {
-		fmt.Printf("Delete SQL: %s\n", sql)
-		fmt.Printf("Args: %v\n", q.args)
-	}
+	q.db.debugf("Delete SQL: %s", sql)
+	q.db.debugf("Delete Args: %v", q.args)
 
-	return q.db.Exec(ctx, sql, q.args...)
+	return q.db.Exec(q.context(ctx), sql, q.args...)
 }
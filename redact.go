@@ -0,0 +1,102 @@
+package chorm
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redactedValue заменяет скрытые значения в журналах, ошибках и событиях хуков
+const redactedValue = "***"
+
+// SensitiveValue - значение аргумента запроса, которое отправляется на сервер,
+// но выводится как *** в журналах Debug и событиях хуков. Поля с тегом
+// ch_sensitive:"true" оборачиваются автоматически
+type SensitiveValue struct {
+	value interface{}
+}
+
+// Sensitive помечает значение аргумента как скрываемое
+func Sensitive(value interface{}) SensitiveValue {
+	return SensitiveValue{value: value}
+}
+
+// Raw возвращает исходное значение
+func (v SensitiveValue) Raw() interface{} {
+	return v.value
+}
+
+// String реализует fmt.Stringer
+func (v SensitiveValue) String() string {
+	return redactedValue
+}
+
+// GoString реализует fmt.GoStringer
+func (v SensitiveValue) GoString() string {
+	return redactedValue
+}
+
+// MarshalJSON скрывает значение при сериализации события в JSON
+func (v SensitiveValue) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redactedValue + `"`), nil
+}
+
+// sensitiveArg оборачивает значение поля с тегом ch_sensitive
+func sensitiveArg(field FieldInfo, value interface{}) interface{} {
+	if field.Sensitive {
+		return Sensitive(value)
+	}
+	return value
+}
+
+// driverArgs раскрывает SensitiveValue перед передачей аргументов драйверу
+func driverArgs(args []interface{}) []interface{} {
+	var unwrapped []interface{}
+	for i, arg := range args {
+		v, ok := arg.(SensitiveValue)
+		if !ok {
+			continue
+		}
+		if unwrapped == nil {
+			unwrapped = append([]interface{}(nil), args...)
+		}
+		unwrapped[i] = v.value
+	}
+	if unwrapped == nil {
+		return args
+	}
+	return unwrapped
+}
+
+// redactedError - ошибка с очищенным от пароля текстом, сохраняющая цепочку
+// для errors.Is и errors.As
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError заменяет пароль (в том числе в экранированном для URL виде) в
+// тексте ошибки на ***
+func redactError(err error, password string) error {
+	if err == nil || password == "" {
+		return err
+	}
+
+	msg := err.Error()
+	redacted := msg
+	escaped := strings.TrimPrefix(url.UserPassword("", password).String(), ":")
+	for _, secret := range []string{password, escaped, url.QueryEscape(password)} {
+		redacted = strings.ReplaceAll(redacted, secret, redactedValue)
+	}
+	if redacted == msg {
+		return err
+	}
+	return &redactedError{msg: redacted, err: err}
+}
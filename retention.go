@@ -0,0 +1,106 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ScheduleRetention в отдельной горутине сразу и затем каждые interval
+// удаляет (DROP PARTITION) партиции таблицы, все данные которых старше
+// keepFor. Возраст партиции определяется по верхней границе ключа
+// партиционирования в system.parts (max_time или max_date), поэтому таблица
+// должна быть партиционирована по дате или времени. Ошибка прохода
+// журналируется, проход повторяется через interval.
+//
+// Возвращаемый канал получает nil после отмены ctx либо ошибку параметров
+// и закрывается:
+//
+//	errc := chorm.NewSchema(db).ScheduleRetention(ctx, "events", 30*24*time.Hour, time.Hour)
+func (s *Schema) ScheduleRetention(ctx context.Context, table string, keepFor, interval time.Duration) <-chan error {
+	errc := make(chan error, 1)
+	if keepFor <= 0 || interval <= 0 {
+		errc <- fmt.Errorf("retention period and interval must be positive, got %s and %s", keepFor, interval)
+		close(errc)
+		return errc
+	}
+
+	go func() {
+		defer close(errc)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.dropExpiredPartitions(ctx, table, time.Now().Add(-keepFor)); err != nil && ctx.Err() == nil {
+				s.db.warnf("Retention of %s failed, retrying in %s: %v", table, interval, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				errc <- nil
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return errc
+}
+
+// dropExpiredPartitions удаляет партиции таблицы, все данные которых старше
+// before, и возвращает число удаленных партиций. Таблица без базы ищется в
+// базе UseDatabase, а без нее - в текущей базе соединения
+func (s *Schema) dropExpiredPartitions(ctx context.Context, table string, before time.Time) (int, error) {
+	database, name := splitTable(table)
+	if database == "" {
+		database = s.db.database
+	}
+
+	partitions, err := s.expiredPartitions(ctx, database, name, before)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, partition := range partitions {
+		sql := fmt.Sprintf("ALTER TABLE %s DROP PARTITION ID ?", quoteTable(database, name))
+		if _, err := s.db.Exec(ctx, sql, partition); err != nil {
+			return i, fmt.Errorf("failed to drop partition %s of %s: %w", partition, table, err)
+		}
+		s.db.infof("Dropped partition %s of %s", partition, table)
+	}
+	return len(partitions), nil
+}
+
+// expiredPartitions возвращает идентификаторы активных партиций таблицы,
+// верхняя граница ключа партиционирования которых раньше before
+func (s *Schema) expiredPartitions(ctx context.Context, database, table string, before time.Time) ([]string, error) {
+	sql, args := retentionPartitionsSQL(database, table, before)
+	var parts []struct {
+		PartitionID string `ch:"partition_id"`
+	}
+	if err := s.db.Query(ctx, &parts, sql, args...); err != nil {
+		return nil, fmt.Errorf("failed to get expired partitions of %s: %w", table, err)
+	}
+
+	partitions := make([]string, len(parts))
+	for i, part := range parts {
+		partitions[i] = part.PartitionID
+	}
+	return partitions, nil
+}
+
+// retentionPartitionsSQL строит запрос партиций таблицы к system.parts. Пустая
+// database означает текущую базу соединения. Партиции без ключа даты или
+// времени (граница равна нулю) не выбираются
+func retentionPartitionsSQL(database, table string, before time.Time) (string, []interface{}) {
+	const upper = "greatest(max(max_time), toDateTime(max(max_date)))"
+	where := "active AND database = currentDatabase() AND table = ?"
+	args := []interface{}{table, before}
+	if database != "" {
+		where = "active AND database = ? AND table = ?"
+		args = []interface{}{database, table, before}
+	}
+
+	sql := fmt.Sprintf("SELECT partition_id FROM system.parts WHERE %s GROUP BY partition_id "+
+		"HAVING %s > toDateTime(0) AND %s < ? ORDER BY partition_id", where, upper, upper)
+	return sql, args
+}
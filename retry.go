@@ -0,0 +1,155 @@
+package chorm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// defaultRetryableErrors - коды ошибок сервера, после которых запрос можно
+// повторить: SOCKET_TIMEOUT, NETWORK_ERROR, TOO_MANY_SIMULTANEOUS_QUERIES и
+// ALL_CONNECTION_TRIES_FAILED
+var defaultRetryableErrors = []string{"code: 209", "code: 210", "code: 202", "code: 279"}
+
+// RetryDB выполняет запросы DB с повтором при временных ошибках: разрывах
+// соединения, перезапуске сервера и ошибках из RetryOn. Повтор INSERT может
+// записать данные дважды, если сервер успел выполнить запрос до ошибки
+type RetryDB struct {
+	db          *DB
+	maxAttempts int
+	backoff     time.Duration
+	retryable   []string
+	classify    func(err error) bool
+}
+
+// WithRetry возвращает RetryDB, выполняющую каждый запрос не более
+// maxAttempts раз. Пауза между попытками начинается с backoff и удваивается
+func (db *DB) WithRetry(maxAttempts int, backoff time.Duration) *RetryDB {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &RetryDB{
+		db:          db,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		retryable:   defaultRetryableErrors,
+	}
+}
+
+// RetryOn добавляет подстроки или коды ошибок (например "code: 252"),
+// при которых запрос повторяется
+func (r *RetryDB) RetryOn(patterns ...string) *RetryDB {
+	retryable := make([]string, 0, len(r.retryable)+len(patterns))
+	retryable = append(retryable, r.retryable...)
+	r.retryable = append(retryable, patterns...)
+	return r
+}
+
+// RetryIf заменяет классификацию ошибок IsRetryable функцией fn
+func (r *RetryDB) RetryIf(fn func(err error) bool) *RetryDB {
+	r.classify = fn
+	return r
+}
+
+// DB возвращает исходную DB без повторов
+func (r *RetryDB) DB() *DB {
+	return r.db
+}
+
+// IsRetryable проверяет, можно ли повторить запрос после ошибки. По
+// умолчанию повторяются ошибки соединения и ошибки, содержащие подстроки
+// из RetryOn; RetryIf заменяет эту проверку. Отмена контекста не повторяется
+func (r *RetryDB) IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if r.classify != nil {
+		return r.classify(err)
+	}
+	if isConnectionError(err) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, pattern := range r.retryable {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// do выполняет fn, повторяя ее при ошибках, для которых IsRetryable возвращает true
+func (r *RetryDB) do(ctx context.Context, fn func() error) error {
+	delay := r.backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.maxAttempts || !r.IsRetryable(err) {
+			return err
+		}
+
+		r.db.debugf("Retrying after error (attempt %d of %d): %v", attempt, r.maxAttempts, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// Query выполняет DB.Query с повтором
+func (r *RetryDB) Query(ctx context.Context, result interface{}, query string, args ...interface{}) error {
+	return r.do(ctx, func() error {
+		return r.db.Query(ctx, result, query, args...)
+	})
+}
+
+// QueryRow выполняет DB.QueryRow с повтором
+func (r *RetryDB) QueryRow(ctx context.Context, result interface{}, query string, args ...interface{}) error {
+	return r.do(ctx, func() error {
+		return r.db.QueryRow(ctx, result, query, args...)
+	})
+}
+
+// Exec выполняет DB.Exec с повтором
+func (r *RetryDB) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	var result Result
+	err := r.do(ctx, func() (err error) {
+		result, err = r.db.Exec(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// Insert выполняет DB.Insert с повтором
+func (r *RetryDB) Insert(ctx context.Context, model interface{}) error {
+	return r.do(ctx, func() error {
+		return r.db.Insert(ctx, model)
+	})
+}
+
+// InsertBatch выполняет DB.InsertBatch с повтором
+func (r *RetryDB) InsertBatch(ctx context.Context, models []interface{}) error {
+	return r.do(ctx, func() error {
+		return r.db.InsertBatch(ctx, models)
+	})
+}
+
+// CreateTable выполняет DB.CreateTable с повтором
+func (r *RetryDB) CreateTable(ctx context.Context, model interface{}) error {
+	return r.do(ctx, func() error {
+		return r.db.CreateTable(ctx, model)
+	})
+}
+
+// Ping выполняет DB.Ping с повтором
+func (r *RetryDB) Ping(ctx context.Context) error {
+	return r.do(ctx, func() error {
+		return r.db.Ping(ctx)
+	})
+}
@@ -0,0 +1,154 @@
+package chorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ErrOptimisticLockConflict возвращается Save и SaveOptimistic, если версия
+// записи в таблице отличается от ожидаемой: запись изменена другим клиентом
+var ErrOptimisticLockConflict = errors.New("optimistic lock conflict")
+
+// Save обновляет запись по первичному ключу (ch_pk) мутацией ALTER TABLE ...
+// UPDATE. Если у модели есть поле ch_version, обновление выполняется только
+// при совпадении версии в таблице с версией модели, а после успеха версия
+// модели увеличивается на единицу. При несовпадении возвращается
+// ErrOptimisticLockConflict.
+//
+// ClickHouse не сообщает число измененных мутацией строк, поэтому версия
+// проверяется запросом SELECT перед мутацией, а мутация выполняется с
+// mutations_sync = 1. Проверка и мутация не атомарны: одновременные Save
+// одной записи в узком окне между ними могут не обнаружить конфликт
+func (db *DB) Save(ctx context.Context, model interface{}) error {
+	return db.save(ctx, model, nil)
+}
+
+// SaveOptimistic работает как Save, но сравнивает версию в таблице с
+// currentVersion вместо значения поля ch_version модели. Для вызывающих,
+// которые хранят версию отдельно от модели. После успеха поле ch_version
+// модели равно currentVersion+1
+func (db *DB) SaveOptimistic(ctx context.Context, model interface{}, currentVersion int64) error {
+	return db.save(ctx, model, &currentVersion)
+}
+
+// save обновляет запись; expected задает ожидаемую версию вместо поля модели
+func (db *DB) save(ctx context.Context, model interface{}, expected *int64) error {
+	val := reflect.ValueOf(model)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("model must be a pointer to struct")
+	}
+	val = val.Elem()
+
+	mapper := NewMapper()
+	info, err := mapper.ParseStruct(model)
+	if err != nil {
+		return fmt.Errorf("failed to parse struct: %w", err)
+	}
+	db.applyTimestamps(info, model, time.Now(), false)
+	applyAudit(info, model, db.auditUser(ctx), false)
+	if err := validateModel(info, model); err != nil {
+		return err
+	}
+
+	var pk, version *FieldInfo
+	for i := range info.Fields {
+		switch {
+		case info.Fields[i].IsPK && pk == nil:
+			pk = &info.Fields[i]
+		case info.Fields[i].IsVersion && version == nil:
+			version = &info.Fields[i]
+		}
+	}
+	if pk == nil {
+		return fmt.Errorf("no primary key found")
+	}
+	if version == nil && expected != nil {
+		return fmt.Errorf("model has no ch_version field")
+	}
+
+	pkValue := val.FieldByName(pk.FieldName).Interface()
+	where := fmt.Sprintf("`%s` = ?", pk.Name)
+	whereArgs := []interface{}{pkValue}
+
+	var versionField reflect.Value
+	var current int64
+	if version != nil {
+		versionField = val.FieldByName(version.FieldName)
+		if expected != nil {
+			current = *expected
+		} else {
+			current = versionValue(versionField)
+		}
+		where += fmt.Sprintf(" AND `%s` = ?", version.Name)
+		whereArgs = append(whereArgs, current)
+
+		var count uint64
+		countSQL := fmt.Sprintf("SELECT count() FROM %s WHERE %s", db.quoteTable(info.Name), where)
+		if err := db.QueryRow(ctx, &count, countSQL, whereArgs...); err != nil {
+			return fmt.Errorf("failed to check record version: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %s %v with version %d not found", ErrOptimisticLockConflict, info.Name, pkValue, current)
+		}
+	}
+
+	var assignments []string
+	var values []interface{}
+	for _, field := range info.Fields {
+		if field.IsPK || !field.insertable() {
+			continue
+		}
+
+		assignments = append(assignments, fmt.Sprintf("`%s` = ?", field.Name))
+		if version != nil && field.FieldName == version.FieldName {
+			values = append(values, current+1)
+			continue
+		}
+		values = append(values, sensitiveArg(field, unixTimeArg(field, tupleArg(val.FieldByName(field.FieldName).Interface()))))
+	}
+	if len(assignments) == 0 {
+		return fmt.Errorf("model has no columns to update")
+	}
+	values = append(values, whereArgs...)
+
+	sql := fmt.Sprintf("ALTER TABLE %s UPDATE %s WHERE %s SETTINGS mutations_sync = 1",
+		db.quoteTable(info.Name), strings.Join(assignments, ", "), where)
+
+	db.debugf("Save SQL: %s", sql)
+	db.debugf("Save Args: %v", values)
+
+	ctx, event := db.beforeQuery(ctx, sql, values)
+	_, err = db.execConn(ctx, event)
+	if err := db.finishQuery(ctx, event, 0, err); err != nil {
+		return fmt.Errorf("failed to save record: %w", err)
+	}
+
+	if version != nil {
+		setVersionValue(versionField, current+1)
+	}
+	return nil
+}
+
+// versionValue возвращает значение целочисленного поля версии
+func versionValue(field reflect.Value) int64 {
+	switch field.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(field.Uint())
+	default:
+		return field.Int()
+	}
+}
+
+// setVersionValue записывает версию в целочисленное поле
+func setVersionValue(field reflect.Value, version int64) {
+	switch field.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(version))
+	default:
+		field.SetInt(version)
+	}
+}
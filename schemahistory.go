@@ -0,0 +1,223 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaHistoryEntry представляет запись журнала изменений схемы
+type SchemaHistoryEntry struct {
+	Timestamp time.Time `ch:"timestamp" ch_type:"DateTime64(3)"`
+	Operation string    `ch:"operation" ch_type:"String"`
+	Table     string    `ch:"table_name" ch_type:"String"`
+	Column    string    `ch:"column_name" ch_type:"String"`
+	OldValue  string    `ch:"old_value" ch_type:"String"`
+	NewValue  string    `ch:"new_value" ch_type:"String"`
+	AppliedBy string    `ch:"applied_by" ch_type:"String"`
+}
+
+// schemaHistoryTable - таблица журнала изменений схемы
+const schemaHistoryTable = "schema_history"
+
+// TableName возвращает имя таблицы журнала изменений схемы
+func (e *SchemaHistoryEntry) TableName() string {
+	return schemaHistoryTable
+}
+
+// createSchemaHistorySQL создает таблицу журнала изменений схемы
+const createSchemaHistorySQL = "CREATE TABLE IF NOT EXISTS " + schemaHistoryTable + ` (
+  timestamp DateTime64(3),
+  operation String,
+  table_name String,
+  column_name String,
+  old_value String,
+  new_value String,
+  applied_by String
+) ENGINE = MergeTree() ORDER BY timestamp`
+
+// SchemaHistory оборачивает Schema и записывает каждую успешную DDL операцию
+// в таблицу schema_history. Пользователь (applied_by) берется из
+// WithAuditProvider. Методы чтения схемы выполняются без записи
+type SchemaHistory struct {
+	*Schema
+
+	mu      sync.Mutex
+	created bool
+}
+
+// NewSchemaHistory создает схему с журналом изменений
+func NewSchemaHistory(db *DB) *SchemaHistory {
+	return &SchemaHistory{Schema: NewSchema(db)}
+}
+
+// History возвращает журнал изменений схемы в порядке применения
+func (s *Schema) History(ctx context.Context) ([]SchemaHistoryEntry, error) {
+	var entries []SchemaHistoryEntry
+	err := s.db.Query(ctx, &entries,
+		"SELECT * FROM "+schemaHistoryTable+" ORDER BY timestamp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema history: %w", err)
+	}
+	return entries, nil
+}
+
+// CreateHistoryTable создает таблицу schema_history, если она не существует
+func (h *SchemaHistory) CreateHistoryTable(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.created {
+		return nil
+	}
+	if _, err := h.db.Exec(ctx, createSchemaHistorySQL); err != nil {
+		return fmt.Errorf("failed to create schema history table: %w", err)
+	}
+	h.created = true
+	return nil
+}
+
+// record записывает операцию в журнал, если она выполнена без ошибки
+func (h *SchemaHistory) record(ctx context.Context, err error, entry SchemaHistoryEntry) error {
+	if err != nil {
+		return err
+	}
+	if err := h.CreateHistoryTable(ctx); err != nil {
+		return err
+	}
+
+	entry.Timestamp = time.Now()
+	entry.AppliedBy = h.db.auditUser(ctx)
+	if err := h.db.Insert(ctx, &entry); err != nil {
+		return fmt.Errorf("failed to record schema history: %w", err)
+	}
+	return nil
+}
+
+// columnType возвращает текущий тип колонки или пустую строку, если его не
+// удалось получить
+func (h *SchemaHistory) columnType(ctx context.Context, tableName, columnName string) string {
+	var columnType string
+	err := h.db.QueryRow(ctx, &columnType,
+		"SELECT type FROM system.columns WHERE database = currentDatabase() AND table = ? AND name = ?",
+		strings.Trim(tableName, "`"), strings.Trim(columnName, "`"))
+	if err != nil {
+		h.db.warnf("Failed to get type of column %s.%s: %v", tableName, columnName, err)
+		return ""
+	}
+	return columnType
+}
+
+// CreateDatabase создает базу данных и записывает операцию в журнал
+func (h *SchemaHistory) CreateDatabase(ctx context.Context, name string) error {
+	return h.record(ctx, h.Schema.CreateDatabase(ctx, name),
+		SchemaHistoryEntry{Operation: "CreateDatabase", NewValue: name})
+}
+
+// DropDatabase удаляет базу данных и записывает операцию в журнал
+func (h *SchemaHistory) DropDatabase(ctx context.Context, name string) error {
+	return h.record(ctx, h.Schema.DropDatabase(ctx, name),
+		SchemaHistoryEntry{Operation: "DropDatabase", OldValue: name})
+}
+
+// CreateTable создает таблицу и записывает операцию в журнал
+func (h *SchemaHistory) CreateTable(ctx context.Context, tableName string, columns []string, engine string, options map[string]string) error {
+	return h.record(ctx, h.Schema.CreateTable(ctx, tableName, columns, engine, options),
+		SchemaHistoryEntry{Operation: "CreateTable", Table: tableName, NewValue: strings.Join(columns, ", ")})
+}
+
+// DropTable удаляет таблицу и записывает операцию в журнал
+func (h *SchemaHistory) DropTable(ctx context.Context, tableName string) error {
+	return h.record(ctx, h.Schema.DropTable(ctx, tableName),
+		SchemaHistoryEntry{Operation: "DropTable", Table: tableName})
+}
+
+// TruncateTable очищает таблицу и записывает операцию в журнал
+func (h *SchemaHistory) TruncateTable(ctx context.Context, tableName string) error {
+	return h.record(ctx, h.Schema.TruncateTable(ctx, tableName),
+		SchemaHistoryEntry{Operation: "TruncateTable", Table: tableName})
+}
+
+// RenameTable переименовывает таблицу и записывает операцию в журнал
+func (h *SchemaHistory) RenameTable(ctx context.Context, oldName, newName string) error {
+	return h.record(ctx, h.Schema.RenameTable(ctx, oldName, newName),
+		SchemaHistoryEntry{Operation: "RenameTable", Table: newName, OldValue: oldName, NewValue: newName})
+}
+
+// AddColumn добавляет колонку и записывает операцию в журнал
+func (h *SchemaHistory) AddColumn(ctx context.Context, tableName, columnName, columnType string) error {
+	return h.record(ctx, h.Schema.AddColumn(ctx, tableName, columnName, columnType),
+		SchemaHistoryEntry{Operation: "AddColumn", Table: tableName, Column: columnName, NewValue: columnType})
+}
+
+// DropColumn удаляет колонку и записывает операцию в журнал вместе с ее типом
+func (h *SchemaHistory) DropColumn(ctx context.Context, tableName, columnName string) error {
+	oldType := h.columnType(ctx, tableName, columnName)
+	return h.record(ctx, h.Schema.DropColumn(ctx, tableName, columnName),
+		SchemaHistoryEntry{Operation: "DropColumn", Table: tableName, Column: columnName, OldValue: oldType})
+}
+
+// AddMaterializedColumn добавляет колонку MATERIALIZED и записывает операцию в журнал
+func (h *SchemaHistory) AddMaterializedColumn(ctx context.Context, tableName, columnName, columnType, expr string) error {
+	return h.record(ctx, h.Schema.AddMaterializedColumn(ctx, tableName, columnName, columnType, expr),
+		SchemaHistoryEntry{Operation: "AddMaterializedColumn", Table: tableName, Column: columnName,
+			NewValue: columnType + " MATERIALIZED " + expr})
+}
+
+// AddAliasColumn добавляет колонку ALIAS и записывает операцию в журнал
+func (h *SchemaHistory) AddAliasColumn(ctx context.Context, tableName, columnName, columnType, expr string) error {
+	return h.record(ctx, h.Schema.AddAliasColumn(ctx, tableName, columnName, columnType, expr),
+		SchemaHistoryEntry{Operation: "AddAliasColumn", Table: tableName, Column: columnName,
+			NewValue: columnType + " ALIAS " + expr})
+}
+
+// ModifyColumn изменяет тип колонки и записывает в журнал прежний и новый типы
+func (h *SchemaHistory) ModifyColumn(ctx context.Context, tableName, columnName, newType string) error {
+	oldType := h.columnType(ctx, tableName, columnName)
+	return h.record(ctx, h.Schema.ModifyColumn(ctx, tableName, columnName, newType),
+		SchemaHistoryEntry{Operation: "ModifyColumn", Table: tableName, Column: columnName, OldValue: oldType, NewValue: newType})
+}
+
+// RenameColumn переименовывает колонку и записывает операцию в журнал
+func (h *SchemaHistory) RenameColumn(ctx context.Context, tableName, oldName, newName string) error {
+	return h.record(ctx, h.Schema.RenameColumn(ctx, tableName, oldName, newName),
+		SchemaHistoryEntry{Operation: "RenameColumn", Table: tableName, Column: newName, OldValue: oldName, NewValue: newName})
+}
+
+// CreateIndex создает индекс и записывает операцию в журнал
+func (h *SchemaHistory) CreateIndex(ctx context.Context, indexName, tableName string, columns []string) error {
+	return h.record(ctx, h.Schema.CreateIndex(ctx, indexName, tableName, columns),
+		SchemaHistoryEntry{Operation: "CreateIndex", Table: tableName, NewValue: indexName + " (" + strings.Join(columns, ", ") + ")"})
+}
+
+// DropIndex удаляет индекс и записывает операцию в журнал
+func (h *SchemaHistory) DropIndex(ctx context.Context, indexName, tableName string) error {
+	return h.record(ctx, h.Schema.DropIndex(ctx, indexName, tableName),
+		SchemaHistoryEntry{Operation: "DropIndex", Table: tableName, OldValue: indexName})
+}
+
+// CreateMaterializedView создает материализованное представление и записывает операцию в журнал
+func (h *SchemaHistory) CreateMaterializedView(ctx context.Context, viewName, tableName, selectQuery string) error {
+	return h.record(ctx, h.Schema.CreateMaterializedView(ctx, viewName, tableName, selectQuery),
+		SchemaHistoryEntry{Operation: "CreateMaterializedView", Table: viewName, NewValue: selectQuery})
+}
+
+// DropMaterializedView удаляет материализованное представление и записывает операцию в журнал
+func (h *SchemaHistory) DropMaterializedView(ctx context.Context, viewName string) error {
+	return h.record(ctx, h.Schema.DropMaterializedView(ctx, viewName),
+		SchemaHistoryEntry{Operation: "DropMaterializedView", Table: viewName})
+}
+
+// CreateDictionary создает словарь и записывает операцию в журнал
+func (h *SchemaHistory) CreateDictionary(ctx context.Context, def DictionaryDef) error {
+	return h.record(ctx, h.Schema.CreateDictionary(ctx, def),
+		SchemaHistoryEntry{Operation: "CreateDictionary", Table: def.Name, NewValue: def.BuildCreateSQL()})
+}
+
+// DropDictionary удаляет словарь и записывает операцию в журнал
+func (h *SchemaHistory) DropDictionary(ctx context.Context, name string) error {
+	return h.record(ctx, h.Schema.DropDictionary(ctx, name),
+		SchemaHistoryEntry{Operation: "DropDictionary", Table: name})
+}
@@ -0,0 +1,68 @@
+package chorm
+
+import (
+	"context"
+	"strings"
+)
+
+// ExecScript выполняет SQL-скрипт из нескольких запросов, разделенных ';',
+// через ExecMulti. Комментарии (-- и /* */) удаляются, ';' внутри строк и
+// идентификаторов в кавычках не разделяет запросы
+func (db *DB) ExecScript(ctx context.Context, script string) error {
+	return db.ExecMulti(ctx, SplitStatements(script))
+}
+
+// SplitStatements разбивает SQL-скрипт на запросы по ';' вне строковых
+// литералов, идентификаторов в кавычках и комментариев. Комментарии
+// удаляются, пустые запросы пропускаются
+func SplitStatements(script string) []string {
+	var statements []string
+	var b strings.Builder
+
+	flush := func() {
+		if statement := strings.TrimSpace(b.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		b.Reset()
+	}
+
+	var quote byte
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(script) {
+				b.WriteByte(c)
+				i++
+				c = script[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && i+1 < len(script) && script[i+1] == '-':
+			// Комментарий до конца строки
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+			continue
+		case c == '/' && i+1 < len(script) && script[i+1] == '*':
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+			b.WriteByte(' ')
+			continue
+		case c == ';':
+			flush()
+			continue
+		}
+		b.WriteByte(c)
+	}
+	flush()
+
+	return statements
+}
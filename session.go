@@ -0,0 +1,319 @@
+package chorm
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSessionTimeout - время простоя сессии по умолчанию, совпадает со
+// значением session_timeout сервера
+const defaultSessionTimeout = 60 * time.Second
+
+// ErrSessionClosed возвращается при обращении к закрытой или истекшей сессии
+var ErrSessionClosed = errors.New("session is closed")
+
+// Session представляет серверную сессию, в которой действуют настройки,
+// установленные через SET, и временные таблицы. В нативном протоколе сессия
+// закрепляет соединение из пула, через HTTP использует отдельный пул с
+// параметром session_id
+type Session struct {
+	db        *DB
+	id        string
+	conn      sessionConn
+	closeConn func() error
+	timeout   time.Duration
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	active int
+	err    error
+}
+
+// sessionConn - соединение, на котором выполняются запросы сессии
+// (*sql.Conn для нативного протокола, *sql.DB для HTTP)
+type sessionConn interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// OpenSession открывает сессию. Сессию нужно закрыть через Close; сессия без
+// запросов дольше Config.SessionTimeout закрывается автоматически
+func (db *DB) OpenSession(ctx context.Context) (*Session, error) {
+	if err := db.requireCapability(CapabilityConnectionSession); err != nil {
+		return nil, err
+	}
+
+	timeout := db.config.SessionTimeout
+	if timeout <= 0 {
+		timeout = defaultSessionTimeout
+	}
+	s := &Session{db: db, timeout: timeout}
+
+	if db.Protocol() == ProtocolHTTP {
+		if err := s.openHTTP(ctx); err != nil {
+			return nil, err
+		}
+	} else if err := s.openNative(ctx); err != nil {
+		return nil, err
+	}
+
+	s.timer = time.AfterFunc(timeout, s.expire)
+	return s, nil
+}
+
+// openNative закрепляет соединение из пула. При закрытии соединение не
+// возвращается в пул, чтобы настройки и временные таблицы сессии не
+// достались другим запросам
+func (s *Session) openNative(ctx context.Context) error {
+	pool, err := s.db.pool(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire session connection: %w", err)
+	}
+
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire session connection: %w", classifyError(err))
+	}
+
+	s.conn = conn
+	s.closeConn = func() error {
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		return nil
+	}
+	return nil
+}
+
+// openHTTP открывает пул из одного соединения, передающий session_id и
+// session_timeout с каждым запросом
+func (s *Session) openHTTP(ctx context.Context) error {
+	id, err := newSessionID()
+	if err != nil {
+		return err
+	}
+
+	config := s.db.config
+	config.Settings = make(map[string]interface{}, len(s.db.config.Settings)+2)
+	for k, v := range s.db.config.Settings {
+		config.Settings[k] = v
+	}
+	config.Settings["session_id"] = id
+	config.Settings["session_timeout"] = durationSeconds(s.timeout)
+	// Сервер не допускает параллельных запросов в одной сессии
+	config.MaxOpenConns = 1
+	config.MaxIdleConns = 1
+
+	connect := openDB
+	if s.db.state != nil && s.db.state.connect != nil {
+		connect = s.db.state.connect
+	}
+	conn, err := connect(ctx, config)
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+
+	s.id = id
+	s.conn = conn
+	s.closeConn = conn.Close
+	return nil
+}
+
+// newSessionID генерирует случайный идентификатор HTTP сессии
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate session id: %w", err)
+	}
+	return "chorm-" + hex.EncodeToString(buf), nil
+}
+
+// Session открывает сессию, выполняет fn и закрывает сессию. Все запросы fn
+// выполняются в одной сессии, поэтому настройки, установленные через Set,
+// действуют на последующие запросы
+func (db *DB) Session(ctx context.Context, fn func(s *Session) error) error {
+	s, err := db.OpenSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return fn(s)
+}
+
+// ID возвращает session_id HTTP сессии (пустая строка для нативного протокола)
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Close закрывает сессию. Повторный вызов ничего не делает
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil
+	}
+	s.err = ErrSessionClosed
+	s.timer.Stop()
+	return s.closeConn()
+}
+
+// expire закрывает сессию по истечении времени простоя
+func (s *Session) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil || s.active > 0 {
+		return
+	}
+	s.err = fmt.Errorf("%w: idle for more than %s", ErrSessionClosed, s.timeout)
+	s.closeConn()
+}
+
+// acquire возвращает соединение сессии и останавливает таймер простоя
+// на время запроса
+func (s *Session) acquire() (sessionConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.active++
+	s.timer.Stop()
+	return s.conn, nil
+}
+
+// release завершает запрос и перезапускает таймер простоя
+func (s *Session) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active--
+	if s.active == 0 && s.err == nil {
+		s.timer.Reset(s.timeout)
+	}
+}
+
+// CreateTemporaryTable создает временную таблицу для модели. Таблица
+// существует до закрытия сессии и видна только в ней
+func (s *Session) CreateTemporaryTable(ctx context.Context, model interface{}) error {
+	mapper := NewMapper()
+	info, err := mapper.ParseStruct(model)
+	if err != nil {
+		return fmt.Errorf("failed to parse struct: %w", err)
+	}
+
+	var columns []string
+	for _, field := range info.Fields {
+		columns = append(columns, columnDefinition(field))
+	}
+
+	sql := fmt.Sprintf("CREATE TEMPORARY TABLE IF NOT EXISTS `%s` (\n  %s\n) ENGINE = Memory",
+		info.Name, strings.Join(columns, ",\n  "))
+	if _, err := s.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("failed to create temporary table: %w", err)
+	}
+	return nil
+}
+
+// Set устанавливает настройку для сессии
+func (s *Session) Set(ctx context.Context, key string, value interface{}) error {
+	_, err := s.Exec(ctx, fmt.Sprintf("SET %s = %s", key, formatSettingValue(value)))
+	return err
+}
+
+// Query выполняет запрос в сессии и заполняет результат в slice
+func (s *Session) Query(ctx context.Context, result interface{}, query string, args ...interface{}) error {
+	s.db.debugf("Session Query SQL: %s", query)
+	s.db.debugf("Session Query Args: %v", args)
+
+	conn, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+
+	ctx, event := s.db.beforeQuery(ctx, query, args)
+	rows, err := conn.QueryContext(ctx, event.SQL, driverArgs(event.Args)...)
+	if err != nil {
+		return fmt.Errorf("failed to execute query in session: %w", s.db.finishQuery(ctx, event, 0, err))
+	}
+	defer rows.Close()
+
+	n, err := s.db.scanRows(rows, result)
+	s.db.finishQuery(ctx, event, n, err)
+	return err
+}
+
+// QueryRow выполняет запрос в сессии и возвращает одну строку
+func (s *Session) QueryRow(ctx context.Context, result interface{}, query string, args ...interface{}) error {
+	s.db.debugf("Session QueryRow SQL: %s", query)
+	s.db.debugf("Session QueryRow Args: %v", args)
+
+	conn, err := s.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.release()
+
+	ctx, event := s.db.beforeQuery(ctx, query, args)
+	rows, err := conn.QueryContext(ctx, event.SQL, driverArgs(event.Args)...)
+	if err != nil {
+		return fmt.Errorf("failed to execute query in session: %w", s.db.finishQuery(ctx, event, 0, err))
+	}
+	defer rows.Close()
+
+	err = s.db.scanRow(rows, result)
+	s.db.finishQuery(ctx, event, rowCount(err), err)
+	return err
+}
+
+// Exec выполняет запрос в сессии без возврата результата
+func (s *Session) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	s.db.debugf("Session Exec SQL: %s", query)
+	s.db.debugf("Session Exec Args: %v", args)
+
+	conn, err := s.acquire()
+	if err != nil {
+		return Result{}, err
+	}
+	defer s.release()
+
+	ctx, event := s.db.beforeQuery(ctx, query, args)
+	result, err := conn.ExecContext(ctx, event.SQL, driverArgs(event.Args)...)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to execute query in session: %w", s.db.finishQuery(ctx, event, 0, err))
+	}
+
+	lastInsertID, _ := result.LastInsertId()
+	rowsAffected, _ := result.RowsAffected()
+	s.db.finishQuery(ctx, event, rowsAffected, nil)
+
+	return Result{
+		LastInsertID: lastInsertID,
+		RowsAffected: rowsAffected,
+	}, nil
+}
+
+// formatSettingValue форматирует значение настройки ClickHouse
+func formatSettingValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return quoteString(v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,86 @@
+package chorm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// setOperation представляет операцию над множествами с другим запросом
+type setOperation struct {
+	op    string // EXCEPT, INTERSECT с модификатором ALL или DISTINCT
+	query *Query
+}
+
+// Except добавляет EXCEPT: результат содержит строки запроса, которых нет в
+// other. Запрос other копируется, аргументы добавляются после аргументов
+// запроса. ORDER BY и LIMIT каждого запроса относятся к его собственному
+// SELECT. INTERSECT выполняется раньше EXCEPT, как в ClickHouse:
+//
+//	active := db.NewQuery().Table("users").Select("id").Where("active = ?", 1)
+//	banned := db.NewQuery().Table("bans").Select("user_id")
+//	err := active.Except(banned).All(ctx, &ids)
+func (q *Query) Except(other *Query) *Query {
+	return q.setOperation("EXCEPT", other)
+}
+
+// ExceptAll добавляет EXCEPT ALL: повторяющиеся строки учитываются с кратностью
+func (q *Query) ExceptAll(other *Query) *Query {
+	return q.setOperation("EXCEPT ALL", other)
+}
+
+// ExceptDistinct добавляет EXCEPT DISTINCT: повторяющиеся строки результата удаляются
+func (q *Query) ExceptDistinct(other *Query) *Query {
+	return q.setOperation("EXCEPT DISTINCT", other)
+}
+
+// Intersect добавляет INTERSECT: результат содержит строки, которые есть и в
+// запросе, и в other. Аргументы other добавляются после аргументов запроса
+func (q *Query) Intersect(other *Query) *Query {
+	return q.setOperation("INTERSECT", other)
+}
+
+// IntersectAll добавляет INTERSECT ALL: повторяющиеся строки учитываются с кратностью
+func (q *Query) IntersectAll(other *Query) *Query {
+	return q.setOperation("INTERSECT ALL", other)
+}
+
+// IntersectDistinct добавляет INTERSECT DISTINCT: повторяющиеся строки результата удаляются
+func (q *Query) IntersectDistinct(other *Query) *Query {
+	return q.setOperation("INTERSECT DISTINCT", other)
+}
+
+// setOperation добавляет операцию op с копией запроса other
+func (q *Query) setOperation(op string, other *Query) *Query {
+	if other == nil {
+		q.err = fmt.Errorf("%s requires a query", op)
+		return q
+	}
+	q.setOps = append(q.setOps, setOperation{op: op, query: other.Clone()})
+	return q
+}
+
+// buildSetOperations строит части EXCEPT/INTERSECT с запросами в скобках
+func (q *Query) buildSetOperations() string {
+	parts := make([]string, len(q.setOps))
+	for i, setOp := range q.setOps {
+		parts[i] = fmt.Sprintf("%s (%s)", setOp.op, setOp.query.buildQuery())
+	}
+	return strings.Join(parts, " ")
+}
+
+// queryArgs возвращает аргументы запроса вместе с аргументами табличных
+// выражений WITH, GROUP BY, HAVING и запросов операций над множествами в
+// порядке их плейсхолдеров
+func (q *Query) queryArgs() []interface{} {
+	if len(q.setOps) == 0 && len(q.ctes) == 0 && len(q.selectArgs) == 0 && len(q.groupByArgs) == 0 && len(q.havingArgs) == 0 {
+		return q.args
+	}
+	args := append(q.withArgs(), q.selectArgs...)
+	args = append(args, q.args...)
+	args = append(args, q.groupByArgs...)
+	args = append(args, q.havingArgs...)
+	for _, setOp := range q.setOps {
+		args = append(args, setOp.query.queryArgs()...)
+	}
+	return args
+}
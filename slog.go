@@ -0,0 +1,106 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Ключи атрибутов slog в записях о запросах
+const (
+	SlogKeySQL       = "chorm.sql"
+	SlogKeyDuration  = "chorm.duration_ms"
+	SlogKeyRows      = "chorm.rows"
+	SlogKeyTable     = "chorm.table"
+	SlogKeyQueryID   = "chorm.query_id"
+	SlogKeyOperation = "chorm.operation"
+	SlogKeyError     = "chorm.error"
+)
+
+// slogAttrsKey - ключ контекста с атрибутами WithLogAttrs
+type slogAttrsKey struct{}
+
+// queryIDKey - ключ контекста с идентификатором WithQueryID
+type queryIDKey struct{}
+
+// WithLogAttrs возвращает контекст, атрибуты которого добавляются к каждой
+// записи slog о запросах, выполненных с этим контекстом. Аргументы
+// объединяются в группу name (без группы, если name пусто), как в
+// slog.Group. Повторные вызовы добавляют атрибуты к уже заданным:
+//
+//	ctx = chorm.WithLogAttrs(ctx, "request", "id", requestID, "user", userID)
+func WithLogAttrs(ctx context.Context, name string, args ...any) context.Context {
+	var attrs []slog.Attr
+	if name == "" {
+		attrs = slog.Group("", args...).Value.Group()
+	} else {
+		attrs = []slog.Attr{slog.Group(name, args...)}
+	}
+
+	inherited, _ := ctx.Value(slogAttrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(inherited)+len(attrs))
+	merged = append(merged, inherited...)
+	return context.WithValue(ctx, slogAttrsKey{}, append(merged, attrs...))
+}
+
+// WithQueryID возвращает контекст с идентификатором запроса, который
+// журналируется атрибутом chorm.query_id. Идентификатор не передается
+// серверу: для связи с system.query_log передайте его и драйверу
+func WithQueryID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, queryIDKey{}, id)
+}
+
+// LogAttrs возвращает атрибуты slog события с ключами chorm.*. Хуки
+// используют их, чтобы журналировать запросы в едином формате
+func (e *QueryEvent) LogAttrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String(SlogKeyOperation, string(e.Operation)),
+		slog.String(SlogKeySQL, truncateSQL(e.SQL)),
+		slog.Float64(SlogKeyDuration, float64(e.Duration.Microseconds())/1000),
+		slog.Int64(SlogKeyRows, e.Rows),
+	}
+	if e.Table != "" {
+		attrs = append(attrs, slog.String(SlogKeyTable, e.Table))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String(SlogKeyError, e.Err.Error()))
+	}
+	return attrs
+}
+
+// logQuerySlog журналирует запрос в Config.Slog с атрибутами события и контекста
+func (db *DB) logQuerySlog(ctx context.Context, level slog.Level, msg string, event *QueryEvent) {
+	if !db.config.Slog.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := event.LogAttrs()
+	if id, ok := ctx.Value(queryIDKey{}).(string); ok && id != "" {
+		attrs = append(attrs, slog.String(SlogKeyQueryID, id))
+	}
+	if inherited, ok := ctx.Value(slogAttrsKey{}).([]slog.Attr); ok {
+		attrs = append(attrs, inherited...)
+	}
+	db.config.Slog.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// slogLogger передает сообщения Logger в slog.Logger
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Debugf(format string, args ...interface{}) {
+	s.l.Debug(fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Infof(format string, args ...interface{}) {
+	s.l.Info(fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Warnf(format string, args ...interface{}) {
+	s.l.Warn(fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Errorf(format string, args ...interface{}) {
+	s.l.Error(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,150 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Model устанавливает таблицу модели (TableName или имя типа). Если у модели
+// есть поле ch_soft_delete, запрос исключает мягко удаленные записи, а
+// Delete помечает записи вместо удаления. Поля ch_updated_at и
+// ch_updated_by обновляются в BulkUpdate
+func (q *Query) Model(model interface{}) *Query {
+	info, err := NewMapper().ParseStruct(model)
+	if err != nil {
+		q.err = fmt.Errorf("failed to parse struct: %w", err)
+		return q
+	}
+
+	q.Table(fmt.Sprintf("`%s`", info.Name))
+	q.softDelete, q.updatedAt, q.updatedBy = nil, nil, nil
+	for i, field := range info.Fields {
+		switch {
+		case field.SoftDelete && q.softDelete == nil:
+			q.softDelete = &info.Fields[i]
+		case field.UpdatedAt && q.updatedAt == nil:
+			q.updatedAt = &info.Fields[i]
+		case field.UpdatedBy && q.updatedBy == nil:
+			q.updatedBy = &info.Fields[i]
+		}
+	}
+	return q
+}
+
+// WithTrashed включает в результат мягко удаленные записи
+func (q *Query) WithTrashed() *Query {
+	q.withTrashed = true
+	return q
+}
+
+// whereConditions возвращает условия WHERE с условием исключения мягко
+// удаленных записей
+func (q *Query) whereConditions() []string {
+	if q.softDelete == nil || q.withTrashed {
+		return q.wheres
+	}
+	wheres := make([]string, 0, len(q.wheres)+1)
+	wheres = append(wheres, q.wheres...)
+	return append(wheres, softDeleteCondition(*q.softDelete))
+}
+
+// softDeleteRows помечает записи запроса удаленными мутацией ALTER TABLE ... UPDATE
+func (q *Query) softDeleteRows(ctx context.Context) (Result, error) {
+	where := "1"
+	if wheres := q.whereConditions(); len(wheres) > 0 {
+		where = strings.Join(wheres, " AND ")
+	}
+
+	sql := q.rebind(fmt.Sprintf("ALTER TABLE %s UPDATE `%s` = now() WHERE %s",
+		q.tableName(), q.softDelete.Name, where))
+
+	q.db.debugf("Delete SQL: %s", sql)
+	q.db.debugf("Delete Args: %v", q.args)
+
+	return q.db.Exec(q.context(ctx), sql, q.args...)
+}
+
+// Restore снимает пометку мягкого удаления с записи по первичному ключу
+// (ch_pk) и очищает поле ch_soft_delete модели
+func (db *DB) Restore(ctx context.Context, model interface{}) error {
+	val := reflect.ValueOf(model)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("model must be a pointer to struct")
+	}
+	val = val.Elem()
+
+	info, err := NewMapper().ParseStruct(model)
+	if err != nil {
+		return fmt.Errorf("failed to parse struct: %w", err)
+	}
+	field, ok := softDeleteField(info)
+	if !ok {
+		return fmt.Errorf("model has no ch_soft_delete field")
+	}
+
+	var pk *FieldInfo
+	for i := range info.Fields {
+		if info.Fields[i].IsPK {
+			pk = &info.Fields[i]
+			break
+		}
+	}
+	if pk == nil {
+		return fmt.Errorf("no primary key found")
+	}
+
+	sql := fmt.Sprintf("ALTER TABLE %s UPDATE `%s` = %s WHERE `%s` = ? SETTINGS mutations_sync = 1",
+		db.quoteTable(info.Name), field.Name, softDeleteZero(field), pk.Name)
+	args := []interface{}{val.FieldByName(pk.FieldName).Interface()}
+
+	db.debugf("Restore SQL: %s", sql)
+	db.debugf("Restore Args: %v", args)
+
+	ctx, event := db.beforeQuery(ctx, sql, args)
+	_, err = db.execConn(ctx, event)
+	if err := db.finishQuery(ctx, event, 0, err); err != nil {
+		return fmt.Errorf("failed to restore record: %w", err)
+	}
+
+	deletedAt := val.FieldByName(field.FieldName)
+	deletedAt.Set(reflect.Zero(deletedAt.Type()))
+	return nil
+}
+
+// softDeleteField возвращает поле ch_soft_delete модели
+func softDeleteField(info *TableInfo) (FieldInfo, bool) {
+	for _, field := range info.Fields {
+		if field.SoftDelete {
+			return field, true
+		}
+	}
+	return FieldInfo{}, false
+}
+
+// softDeleteZero возвращает значение колонки неудаленной записи: NULL для
+// Nullable(DateTime) и начало эпохи для DateTime
+func softDeleteZero(field FieldInfo) string {
+	if field.Nullable || strings.HasPrefix(field.Type, "Nullable(") {
+		return "NULL"
+	}
+	return "toDateTime(0)"
+}
+
+// softDeleteCondition возвращает условие, исключающее мягко удаленные записи
+func softDeleteCondition(field FieldInfo) string {
+	if softDeleteZero(field) == "NULL" {
+		return fmt.Sprintf("`%s` IS NULL", field.Name)
+	}
+	return fmt.Sprintf("`%s` = toDateTime(0)", field.Name)
+}
+
+// isSoftDeleteType проверяет, что поле ch_soft_delete имеет тип time.Time или *time.Time
+func isSoftDeleteType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ == reflect.TypeOf(time.Time{})
+}
@@ -0,0 +1,115 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sqlMigrationFile соответствует файлам NNN_name.up.sql и NNN_name.down.sql
+var sqlMigrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// AddSQLMigration добавляет миграцию из SQL-скриптов: upSQL и downSQL
+// выполняются через ExecScript и могут содержать несколько запросов. Пустой
+// downSQL означает миграцию без отката. Контрольная сумма вычисляется по
+// тексту скриптов
+func (m *Migrator) AddSQLMigration(name, upSQL, downSQL string) *Migrator {
+	record := MigrationRecord{
+		Name:     name,
+		UpSQL:    upSQL,
+		DownSQL:  downSQL,
+		Up:       sqlMigrationFunc(upSQL),
+		Checksum: migrationChecksum(name, "sql", upSQL, downSQL),
+	}
+	if strings.TrimSpace(downSQL) != "" {
+		record.Down = sqlMigrationFunc(downSQL)
+	}
+	return m.AddMigrationRecord(record)
+}
+
+// sqlMigrationFunc возвращает функцию миграции, выполняющую скрипт
+func sqlMigrationFunc(script string) MigrationFunc {
+	return func(ctx context.Context, db *DB) error {
+		return db.ExecScript(ctx, script)
+	}
+}
+
+// LoadDir добавляет SQL-миграции из файлов NNN_name.up.sql и
+// NNN_name.down.sql каталога dir (см. LoadFS)
+func (m *Migrator) LoadDir(dir string) error {
+	return m.LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS добавляет SQL-миграции из файлов NNN_name.up.sql и
+// NNN_name.down.sql каталога dir в fsys, например из embed.FS. Миграция
+// называется NNN_name и добавляется в порядке числового префикса. Файл
+// .down.sql необязателен. Повторяющийся номер или .down.sql без .up.sql
+// возвращают ошибку, и ни одна миграция не добавляется. Остальные файлы
+// пропускаются
+func (m *Migrator) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	type sqlMigration struct {
+		number   uint64
+		name     string
+		up, down string
+		hasUp    bool
+	}
+	byNumber := make(map[uint64]*sqlMigration)
+
+	for _, entry := range entries {
+		match := sqlMigrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		number, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid migration number in %s: %w", entry.Name(), err)
+		}
+		name := match[1] + "_" + match[2]
+
+		migration, ok := byNumber[number]
+		if !ok {
+			migration = &sqlMigration{number: number, name: name}
+			byNumber[number] = migration
+		} else if migration.name != name {
+			return fmt.Errorf("duplicate migration number %d: %s and %s", number, migration.name, name)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		if match[3] == "up" {
+			migration.up, migration.hasUp = string(content), true
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]*sqlMigration, 0, len(byNumber))
+	for _, migration := range byNumber {
+		if !migration.hasUp {
+			return fmt.Errorf("migration %s has no .up.sql file", migration.name)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].number < migrations[j].number
+	})
+
+	for _, migration := range migrations {
+		m.AddSQLMigration(migration.name, migration.up, migration.down)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// InsertStream читает записи из rows и вставляет их пакетами InsertBatch.
+// Пакет отправляется, когда набрано batchSize записей или когда с момента
+// поступления первой неотправленной записи прошло flushInterval, поэтому
+// редкие записи не задерживаются до заполнения пакета (flushInterval <= 0
+// отключает отправку по времени). После закрытия rows оставшиеся записи
+// отправляются и InsertStream возвращает nil. При отмене ctx или ошибке
+// вставки чтение прекращается, неотправленные записи теряются:
+//
+//	rows := make(chan interface{})
+//	go func() {
+//		defer close(rows)
+//		for event := range events {
+//			rows <- event
+//		}
+//	}()
+//	err := db.InsertStream(ctx, rows, 10000, time.Second)
+func (db *DB) InsertStream(ctx context.Context, rows <-chan interface{}, batchSize int, flushInterval time.Duration) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+
+	batch := make([]interface{}, 0, batchSize)
+	sent := 0
+
+	// timer запущен, пока в пакете есть записи; timeout - его канал или nil
+	timer := time.NewTimer(flushInterval)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+	var timeout <-chan time.Time
+
+	flush := func() error {
+		if timeout != nil && !timer.Stop() {
+			<-timer.C
+		}
+		timeout = nil
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := db.InsertBatch(ctx, batch); err != nil {
+			return fmt.Errorf("failed to insert stream batch after %d rows: %w", sent, err)
+		}
+		sent += len(batch)
+		batch = make([]interface{}, 0, batchSize)
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case row, ok := <-rows:
+			if !ok {
+				return flush()
+			}
+			batch = append(batch, row)
+			if len(batch) >= batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			} else if len(batch) == 1 && flushInterval > 0 {
+				timer.Reset(flushInterval)
+				timeout = timer.C
+			}
+
+		case <-timeout:
+			timeout = nil
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package chorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// applyTimestamps при включенном Config.SetTimestamps присваивает время now
+// полям ch_updated_at, а при вставке (insert) - и нулевым полям
+// ch_created_at. Модель, переданная по значению, копируется: возвращается
+// модель, из которой читаются значения
+func (db *DB) applyTimestamps(info *TableInfo, model interface{}, now time.Time, insert bool) interface{} {
+	if !db.config.SetTimestamps || !hasTimestamps(info) {
+		return model
+	}
+
+	elem, model := settableModel(model)
+	if !elem.IsValid() {
+		return model
+	}
+
+	for _, field := range info.Fields {
+		value := elem.FieldByName(field.FieldName)
+		switch {
+		case field.UpdatedAt:
+			value.Set(reflect.ValueOf(now))
+		case field.CreatedAt && insert && value.IsZero():
+			value.Set(reflect.ValueOf(now))
+		}
+	}
+	return model
+}
+
+// settableModel возвращает изменяемую структуру модели и модель, из которой
+// читаются значения. Модель, переданная по значению, копируется. Для nil
+// указателя возвращается нулевой reflect.Value
+func settableModel(model interface{}) (reflect.Value, interface{}) {
+	val := reflect.ValueOf(model)
+	if val.Kind() != reflect.Ptr {
+		copied := reflect.New(val.Type())
+		copied.Elem().Set(val)
+		val, model = copied, copied.Interface()
+	}
+	if val.IsNil() {
+		return reflect.Value{}, model
+	}
+	return val.Elem(), model
+}
+
+// hasTimestamps проверяет, есть ли у модели поля ch_created_at или ch_updated_at
+func hasTimestamps(info *TableInfo) bool {
+	for _, field := range info.Fields {
+		if field.CreatedAt || field.UpdatedAt {
+			return true
+		}
+	}
+	return false
+}
+
+// BulkUpdate обновляет все записи запроса мутацией ALTER TABLE ... UPDATE:
+// колонкам data присваиваются значения (колонки сортируются по имени). Для
+// модели из Model при включенном Config.SetTimestamps поле ch_updated_at
+// получает текущее время, а при заданном WithAuditProvider поле
+// ch_updated_by - пользователя, если они не заданы в data
+func (q *Query) BulkUpdate(ctx context.Context, data map[string]interface{}) (Result, error) {
+	if err := q.validate(); err != nil {
+		return Result{}, err
+	}
+	if len(data) == 0 {
+		return Result{}, fmt.Errorf("no data to update")
+	}
+
+	values := make(map[string]interface{}, len(data)+1)
+	for column, value := range data {
+		values[column] = value
+	}
+	if q.updatedAt != nil && q.db.config.SetTimestamps {
+		if _, ok := values[q.updatedAt.Name]; !ok {
+			values[q.updatedAt.Name] = time.Now()
+		}
+	}
+	if q.updatedBy != nil {
+		if _, ok := values[q.updatedBy.Name]; !ok {
+			if user := q.db.auditUser(ctx); user != "" {
+				values[q.updatedBy.Name] = user
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	sets := make([]string, len(columns))
+	args := make([]interface{}, 0, len(columns)+len(q.args))
+	for i, column := range columns {
+		sets[i] = fmt.Sprintf("`%s` = ?", column)
+		args = append(args, values[column])
+	}
+	args = append(args, q.args...)
+
+	where := "1"
+	if wheres := q.whereConditions(); len(wheres) > 0 {
+		where = strings.Join(wheres, " AND ")
+	}
+
+	sql := q.rebind(fmt.Sprintf("ALTER TABLE %s UPDATE %s WHERE %s",
+		q.tableName(), strings.Join(sets, ", "), where))
+
+	q.db.debugf("BulkUpdate SQL: %s", sql)
+	q.db.debugf("BulkUpdate Args: %v", args)
+
+	return q.db.Exec(q.context(ctx), sql, args...)
+}
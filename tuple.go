@@ -0,0 +1,145 @@
+package chorm
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// isTupleStruct проверяет, что структура отображается в Tuple: это не
+// time.Time и не тип с собственным driver.Valuer
+func isTupleStruct(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ == reflect.TypeOf(time.Time{}) {
+		return false
+	}
+	valuer := reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	return !typ.Implements(valuer) && !reflect.PointerTo(typ).Implements(valuer)
+}
+
+// tupleFields возвращает экспортируемые поля структуры в порядке элементов Tuple
+func tupleFields(typ reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.IsExported() {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// tupleType возвращает тип Tuple(...) для структуры. Тип элемента берется из
+// тега ch_type поля или определяется по Go типу
+func (m *Mapper) tupleType(typ reflect.Type) string {
+	fields := tupleFields(typ)
+	elems := make([]string, len(fields))
+	for i, field := range fields {
+		if chType := field.Tag.Get("ch_type"); chType != "" {
+			elems[i] = chType
+		} else {
+			elems[i] = m.goTypeToClickHouseType(field.Type)
+		}
+	}
+	return fmt.Sprintf("Tuple(%s)", strings.Join(elems, ", "))
+}
+
+// tupleArg преобразует структуры Tuple в значении аргумента, в том числе
+// внутри срезов (Array(Tuple(...))), в []interface{} с элементами по
+// порядку полей, как их принимает драйвер ClickHouse
+func tupleArg(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	converted, ok := tupleValue(reflect.ValueOf(value))
+	if !ok {
+		return value
+	}
+	return converted
+}
+
+// tupleValue преобразует значение со структурами Tuple и сообщает, было ли
+// оно изменено
+func tupleValue(v reflect.Value) (interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Struct:
+		if !isTupleStruct(v.Type()) {
+			return nil, false
+		}
+		fields := tupleFields(v.Type())
+		tuple := make([]interface{}, len(fields))
+		for i, field := range fields {
+			elem := v.FieldByIndex(field.Index)
+			if converted, ok := tupleValue(elem); ok {
+				tuple[i] = converted
+			} else {
+				tuple[i] = elem.Interface()
+			}
+		}
+		return tuple, true
+	case reflect.Slice, reflect.Array:
+		elem := v.Type().Elem()
+		if !isTupleStruct(elem) && !(elem.Kind() == reflect.Slice && isTupleStruct(elem.Elem())) {
+			return nil, false
+		}
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, false
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i], _ = tupleValue(v.Index(i))
+		}
+		return items, true
+	}
+	return nil, false
+}
+
+// setTupleValue заполняет структуру Tuple из значения драйвера: []interface{}
+// для безымянного кортежа или map[string]interface{} для именованного, где
+// ключ - тег ch или имя поля
+func setTupleValue(field reflect.Value, value interface{}) {
+	fields := tupleFields(field.Type())
+
+	if named, ok := value.(map[string]interface{}); ok {
+		for _, f := range fields {
+			name := f.Tag.Get("ch")
+			if name == "" {
+				name = f.Name
+			}
+			if elem, ok := named[name]; ok {
+				setValue(field.FieldByIndex(f.Index), driverValue(elem))
+			}
+		}
+		return
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return
+	}
+	for i, f := range fields {
+		if i >= rv.Len() {
+			break
+		}
+		setValue(field.FieldByIndex(f.Index), driverValue(rv.Index(i).Interface()))
+	}
+}
+
+// setSliceValue заполняет поле-срез из среза значений драйвера, преобразуя
+// каждый элемент через setValue
+func setSliceValue(field reflect.Value, value interface{}) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return
+	}
+	if rv.Type().AssignableTo(field.Type()) {
+		field.Set(rv)
+		return
+	}
+
+	slice := reflect.MakeSlice(field.Type(), rv.Len(), rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		setValue(slice.Index(i), driverValue(rv.Index(i).Interface()))
+	}
+	field.Set(slice)
+}
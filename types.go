@@ -1,7 +1,10 @@
 package chorm
 
 import (
+	"context"
 	"database/sql"
+	"io"
+	"log/slog"
 	"time"
 )
 
@@ -16,16 +19,82 @@ type Config struct {
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	TLS             bool
-	Compression     bool
-	Debug           bool
+	TLSCertFile     string           // Клиентский сертификат (PEM)
+	TLSKeyFile      string           // Ключ клиентского сертификата (PEM)
+	TLSCAFile       string           // Сертификат удостоверяющего центра (PEM)
+	Compression     bool             // Включает сжатие LZ4 (если CompressionMethod не задан)
+	Debug           bool             // Журналирует SQL и аргументы запросов через Logger
+	Logger          Logger           // Получатель журнала (по умолчанию stdout в режиме Debug)
+	DebugWriter     io.Writer        // Вывод журнала по умолчанию вместо stdout (без Logger)
+	Slog            *slog.Logger     // Получатель журнала с атрибутами chorm.* (приоритет над Logger)
+	SetTimestamps   bool             // Заполняет поля ch_created_at и ch_updated_at при записи
+	Protocol        Protocol         // native (по умолчанию) или http
+	Placeholder     PlaceholderStyle // Стиль плейсхолдеров построителя запросов (по умолчанию ?)
+	DefaultEngine   string           // Движок CreateTable для моделей без тега ch_engine (по умолчанию MergeTree)
+
+	// CompressionMethod выбирает алгоритм сжатия (имеет приоритет над Compression),
+	// CompressionLevel - уровень сжатия (0 - уровень драйвера по умолчанию)
+	CompressionMethod CompressionMethod
+	CompressionLevel  int
+
+	// Таймауты: нулевое значение означает значение по умолчанию драйвера
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MaxExecutionTime ограничивает время выполнения запроса на сервере
+	// (нулевое значение - значение сервера по умолчанию)
+	MaxExecutionTime time.Duration
+	// SlowQueryThreshold включает журналирование только запросов, выполнявшихся
+	// дольше порога (нулевое значение - в режиме Debug журналируются все запросы)
+	SlowQueryThreshold time.Duration
+	// SessionTimeout - время простоя, после которого сессия закрывается
+	// (нулевое значение - 60 секунд, как session_timeout сервера)
+	SessionTimeout time.Duration
+	// KeepAliveInterval включает периодическую проверку простаивающих
+	// соединений, которые иначе может разорвать балансировщик (нулевое
+	// значение - проверка отключена)
+	KeepAliveInterval time.Duration
+
+	// Settings - настройки ClickHouse, применяемые ко всем запросам соединения.
+	// Настройки запроса (Query.Setting) имеют приоритет над ними
+	Settings map[string]interface{}
+
+	// OnConnect вызывается после каждого успешного подключения, в том числе
+	// после переподключения. Ошибка OnConnect прерывает подключение
+	OnConnect func(db *DB) error
+	// OnReconnect вызывается перед попыткой переподключения после ошибки соединения
+	OnReconnect func(err error)
 }
 
 // DB представляет основное соединение с ClickHouse
 type DB struct {
-	conn   *sql.DB
-	config Config
+	conn           *sql.DB
+	config         Config
+	rowTransformer RowTransformer
+	auditProvider  AuditProvider
+	hooks          []Hook
+	dryRun         *dryRunRecorder
+	state          *connState // Пул соединений Connect и ConnectLazy; если не задан, используется conn
+	tx             *sql.Tx    // Транзакция, в которой выполняются запросы DB из Tx.NewQuery
+	database       string     // База данных UseDatabase для неуточненных имен таблиц
+}
+
+// Conn - операции DB, от которых обычно зависят сервисы и обработчики.
+// Принимайте Conn вместо *DB, чтобы подменять базу в тестах (chormtest.FakeDB)
+type Conn interface {
+	Query(ctx context.Context, result interface{}, query string, args ...interface{}) error
+	QueryRow(ctx context.Context, result interface{}, query string, args ...interface{}) error
+	Exec(ctx context.Context, query string, args ...interface{}) (Result, error)
+	Insert(ctx context.Context, model interface{}) error
+	InsertBatch(ctx context.Context, models []interface{}) error
+	NewQuery() *Query
 }
 
+var _ Conn = (*DB)(nil)
+
+// RowTransformer преобразует сырое значение колонки перед записью в поле структуры
+type RowTransformer func(columnName string, rawValue interface{}) interface{}
+
 // QueryBuilder представляет построитель запросов
 type QueryBuilder struct {
 	table   string
@@ -45,20 +114,35 @@ type Model interface {
 
 // FieldInfo содержит информацию о поле структуры
 type FieldInfo struct {
-	Name     string
-	Type     string
-	Tag      string
-	IsPK     bool
-	IsAuto   bool
-	Nullable bool
+	Name         string
+	FieldName    string // Имя поля в Go структуре
+	Type         string
+	Tag          string
+	IsPK         bool
+	IsAuto       bool
+	Nullable     bool
+	Materialized string // Выражение MATERIALIZED
+	Alias        string // Выражение ALIAS
+	Sensitive    bool   // Значение скрывается в журналах и событиях хуков (ch_sensitive)
+	Required     bool   // Нулевое значение запрещено при вставке (ch_required)
+	MaxLength    int    // Максимальная длина строки в символах при вставке (ch_max, 0 - без ограничения)
+	IsVersion    bool   // Версия записи для оптимистической блокировки в Save (ch_version)
+	UnixTime     bool   // time.Time хранится как Unix-время в целочисленной колонке (ch_unixtime)
+	SoftDelete   bool   // Время мягкого удаления записи (ch_soft_delete)
+	CreatedAt    bool   // Время создания, заполняется при вставке (ch_created_at)
+	UpdatedAt    bool   // Время изменения, обновляется при записи (ch_updated_at)
+	CreatedBy    bool   // Пользователь, создавший запись (ch_created_by)
+	UpdatedBy    bool   // Пользователь, изменивший запись (ch_updated_by)
 }
 
 // TableInfo содержит информацию о таблице
 type TableInfo struct {
 	Name    string
 	Fields  []FieldInfo
-	Engine  string
+	Engine  string // Движок из тега ch_engine (пусто, если не задан)
 	Options map[string]string
+
+	database string // База данных UseDatabase, которой уточняется Name в DDL
 }
 
 // ClickHouseType представляет типы данных ClickHouse
@@ -94,6 +178,34 @@ const (
 	TypeMap            ClickHouseType = "Map"
 )
 
+// Protocol представляет протокол подключения к ClickHouse
+type Protocol string
+
+const (
+	ProtocolNative Protocol = "native"
+	ProtocolHTTP   Protocol = "http"
+)
+
+// PlaceholderStyle представляет стиль плейсхолдеров в генерируемом SQL
+type PlaceholderStyle string
+
+const (
+	PlaceholderQuestion PlaceholderStyle = "?"  // ?, ?, ?
+	PlaceholderDollar   PlaceholderStyle = "$"  // $1, $2, $3
+	PlaceholderAtP      PlaceholderStyle = "@p" // @p1, @p2, @p3
+)
+
+// CompressionMethod представляет алгоритм сжатия данных между клиентом и сервером
+type CompressionMethod string
+
+const (
+	CompressionLZ4     CompressionMethod = "lz4"
+	CompressionZSTD    CompressionMethod = "zstd"
+	CompressionGZIP    CompressionMethod = "gzip"    // Только HTTP
+	CompressionDeflate CompressionMethod = "deflate" // Только HTTP
+	CompressionBrotli  CompressionMethod = "br"      // Только HTTP
+)
+
 // Engine представляет движки таблиц ClickHouse
 type Engine string
 
@@ -0,0 +1,105 @@
+package chorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// unixTimeTypes - целочисленные типы колонок, в которых time.Time хранится
+// как Unix-время в секундах
+var unixTimeTypes = map[string]reflect.Type{
+	string(TypeUInt32): reflect.TypeOf(uint32(0)),
+	string(TypeUInt64): reflect.TypeOf(uint64(0)),
+	string(TypeInt32):  reflect.TypeOf(int32(0)),
+	string(TypeInt64):  reflect.TypeOf(int64(0)),
+}
+
+// parseUnixTime отмечает поле time.Time, хранимое как Unix-время: с тегом
+// ch_unixtime:"true" (тип колонки по умолчанию UInt32) или с целочисленным
+// ch_type
+func parseUnixTime(field reflect.StructField, info *FieldInfo) error {
+	tagged := field.Tag.Get("ch_unixtime") == "true"
+	if !isTimeType(field.Type) {
+		if tagged {
+			return fmt.Errorf("ch_unixtime is supported only for time.Time fields, got %s", field.Type)
+		}
+		return nil
+	}
+
+	if tagged && field.Tag.Get("ch_type") == "" {
+		info.Type = string(TypeUInt32)
+	}
+	if _, ok := unixTimeTypes[unixTimeColumnType(info.Type)]; ok {
+		info.UnixTime = true
+	} else if tagged {
+		return fmt.Errorf("ch_unixtime requires an integer column type, got %s", info.Type)
+	}
+	return nil
+}
+
+// isTimeType проверяет, что тип - time.Time или *time.Time
+func isTimeType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ == reflect.TypeOf(time.Time{})
+}
+
+// unixTimeColumnType возвращает тип колонки без Nullable(...)
+func unixTimeColumnType(chType string) string {
+	if strings.HasPrefix(chType, "Nullable(") && strings.HasSuffix(chType, ")") {
+		return chType[len("Nullable(") : len(chType)-1]
+	}
+	return chType
+}
+
+// unixTimeArg преобразует значение поля Unix-времени в целое число типа
+// колонки. Нулевое время записывается как 0, nil указатель - как NULL
+func unixTimeArg(field FieldInfo, value interface{}) interface{} {
+	if !field.UnixTime {
+		return value
+	}
+
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		t = *v
+	default:
+		return value
+	}
+
+	var seconds int64
+	if !t.IsZero() {
+		seconds = t.Unix()
+	}
+	return reflect.ValueOf(seconds).Convert(unixTimeTypes[unixTimeColumnType(field.Type)]).Interface()
+}
+
+// unixTimeValue преобразует целочисленное значение колонки в time.Time (UTC).
+// 0 соответствует нулевому времени
+func unixTimeValue(value interface{}) (time.Time, bool) {
+	var seconds int64
+	switch v := value.(type) {
+	case int64:
+		seconds = v
+	case int32:
+		seconds = int64(v)
+	case uint64:
+		seconds = int64(v)
+	case uint32:
+		seconds = int64(v)
+	default:
+		return time.Time{}, false
+	}
+	if seconds == 0 {
+		return time.Time{}, true
+	}
+	return time.Unix(seconds, 0).UTC(), true
+}
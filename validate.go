@@ -0,0 +1,78 @@
+package chorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError описывает нарушение ограничения поля модели
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors объединяет все нарушения, найденные при проверке модели
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// validateModel проверяет ограничения ch_required и ch_max и возвращает
+// ValidationErrors (nil, если нарушений нет)
+func validateModel(info *TableInfo, model interface{}) error {
+	val := reflect.ValueOf(model)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs ValidationErrors
+	for _, field := range info.Fields {
+		if !field.Required && field.MaxLength == 0 {
+			continue
+		}
+
+		value := val.FieldByName(field.FieldName)
+		if !value.IsValid() {
+			continue
+		}
+
+		if field.Required && value.IsZero() {
+			errs = append(errs, ValidationError{Field: field.Name, Message: "is required"})
+			continue
+		}
+
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				continue
+			}
+			value = value.Elem()
+		}
+		if field.MaxLength > 0 && value.Kind() == reflect.String {
+			if n := utf8.RuneCountInString(value.String()); n > field.MaxLength {
+				errs = append(errs, ValidationError{
+					Field:   field.Name,
+					Message: fmt.Sprintf("length %d exceeds maximum %d", n, field.MaxLength),
+				})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
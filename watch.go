@@ -0,0 +1,318 @@
+package chorm
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+const (
+	// watchLimit - число обновлений, после которого запрос WATCH
+	// завершается и выполняется заново
+	watchLimit = 100
+	// watchMinBackoff и watchMaxBackoff ограничивают паузу перед повтором
+	// WATCH после разрыва соединения
+	watchMinBackoff = 100 * time.Millisecond
+	watchMaxBackoff = 30 * time.Second
+	// watchKillTimeout ограничивает время выполнения KILL QUERY при отмене
+	watchKillTimeout = 5 * time.Second
+	// watchVersionColumn - колонка WATCH с версией результата Live View
+	watchVersionColumn = "_version"
+	// watchFlushDelay - пауза без новых строк, после которой накопленная
+	// версия считается полной. Строки одного блока драйвер отдает без
+	// ожидания, поэтому пауза означает конец блока
+	watchFlushDelay = 20 * time.Millisecond
+)
+
+// Watch в отдельной горутине выполняет WATCH viewName LIMIT n для Live View
+// и вызывает fn для каждой новой версии результата: строки одной версии
+// (колонка _version) передаются одним пакетом, как только версия сменилась
+// или сервер закончил передавать ее блок. Завершившийся запрос
+// выполняется заново, уже переданные версии пропускаются. При разрыве
+// соединения запрос повторяется с нарастающей паузой. После отмены ctx
+// выполняемому запросу отправляется KILL QUERY по его query_id.
+//
+// Возвращаемый канал получает nil после отмены ctx либо ошибку fn или
+// запроса и закрывается:
+//
+//	errc := db.Watch(ctx, "live_totals", func(rows []map[string]interface{}) error {
+//		log.Println(rows)
+//		return nil
+//	})
+//	if err := <-errc; err != nil {
+//		log.Fatal(err)
+//	}
+func (db *DB) Watch(ctx context.Context, viewName string, fn func(rows []map[string]interface{}) error) <-chan error {
+	return newWatcher(db, viewName, fn).start(ctx)
+}
+
+// watcher выполняет запросы WATCH одного Live View
+type watcher struct {
+	db      *DB
+	view    string
+	limit   int
+	backoff time.Duration
+	flush   time.Duration
+	fn      func(rows []map[string]interface{}) error
+
+	// version - последняя переданная fn версия результата
+	version    uint64
+	hasVersion bool
+}
+
+// newWatcher создает watcher с параметрами по умолчанию
+func newWatcher(db *DB, viewName string, fn func(rows []map[string]interface{}) error) *watcher {
+	return &watcher{
+		db:      db,
+		view:    viewName,
+		limit:   watchLimit,
+		backoff: watchMinBackoff,
+		flush:   watchFlushDelay,
+		fn:      fn,
+	}
+}
+
+// start запускает цикл WATCH в отдельной горутине
+func (w *watcher) start(ctx context.Context) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		errc <- w.run(ctx)
+	}()
+	return errc
+}
+
+// run выполняет WATCH, пока не отменен ctx. Ошибка fn и ошибки, не
+// связанные с соединением, завершают цикл
+func (w *watcher) run(ctx context.Context) error {
+	wait := w.backoff
+	for {
+		queryID, err := newWatchQueryID()
+		if err != nil {
+			return err
+		}
+
+		fnErr, err := w.watchOnce(ctx, queryID)
+		if ctx.Err() != nil {
+			w.kill(ctx, queryID)
+			return nil
+		}
+		if fnErr != nil {
+			return fnErr
+		}
+		if err == nil {
+			wait = w.backoff
+			continue
+		}
+		if !isConnectionError(err) {
+			return fmt.Errorf("failed to watch %s: %w", w.view, err)
+		}
+
+		w.db.warnf("Watch %s failed, retrying in %s: %v", w.view, wait, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		if wait *= 2; wait > watchMaxBackoff {
+			wait = watchMaxBackoff
+		}
+	}
+}
+
+// watchOnce выполняет один запрос WATCH с идентификатором queryID и
+// передает fn новые версии результата. Возвращает ошибку fn и ошибку запроса
+func (w *watcher) watchOnce(ctx context.Context, queryID string) (fnErr, err error) {
+	query := fmt.Sprintf("WATCH %s LIMIT %d", w.view, w.limit)
+	w.db.debugf("Watch SQL: %s", query)
+
+	// Отмена прерывает чтение строк, если fn вернула ошибку
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctx = clickhouse.Context(ctx, clickhouse.WithQueryID(queryID))
+	ctx, event := w.db.beforeQuery(ctx, query, nil)
+	rows, err := w.db.queryConn(ctx, event)
+	if err != nil {
+		return nil, w.db.finishQuery(ctx, event, 0, err)
+	}
+	defer rows.Close()
+
+	n, fnErr, err := w.readVersions(rows, cancel)
+	return fnErr, w.db.finishQuery(ctx, event, n, err)
+}
+
+// watchRows читает строки WATCH в отдельной горутине и отправляет их в
+// канал, который закрывается после последней строки. Ошибка чтения
+// доступна в err после закрытия канала
+type watchRows struct {
+	rows chan map[string]interface{}
+	done chan struct{}
+	err  error
+}
+
+// readWatchRows запускает чтение строк WATCH
+func (w *watcher) readWatchRows(rows *sql.Rows) *watchRows {
+	r := &watchRows{
+		rows: make(chan map[string]interface{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(r.rows)
+
+		columns, err := rows.Columns()
+		if err != nil {
+			r.err = fmt.Errorf("failed to get columns: %w", err)
+			return
+		}
+		scanTypes := columnScanTypes(rows, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+
+		for rows.Next() {
+			for i, scanType := range scanTypes {
+				valuePtrs[i] = reflect.New(scanType).Interface()
+			}
+			if err := rows.Scan(valuePtrs...); err != nil {
+				r.err = fmt.Errorf("failed to scan row: %w", classifyError(err))
+				return
+			}
+
+			row := make(map[string]interface{}, len(columns))
+			for i, column := range columns {
+				value := reflect.ValueOf(valuePtrs[i]).Elem().Interface()
+				if w.db.rowTransformer != nil {
+					value = w.db.rowTransformer(column, value)
+				}
+				row[column] = value
+			}
+
+			select {
+			case r.rows <- row:
+			case <-r.done:
+				return
+			}
+		}
+		r.err = rows.Err()
+	}()
+	return r
+}
+
+// readVersions читает строки WATCH, группирует их по версии и передает
+// fn каждую версию, которая новее уже переданных. Версия передается, когда
+// пришла строка следующей версии или когда строк нет дольше w.flush. Строки
+// уже переданной в этом запросе версии, пришедшие после паузы, передаются
+// отдельным пакетом. При ошибке fn чтение прерывается вызовом cancel
+func (w *watcher) readVersions(rows *sql.Rows, cancel context.CancelFunc) (n int64, fnErr, err error) {
+	reader := w.readWatchRows(rows)
+	defer func() {
+		close(reader.done)
+		if fnErr != nil {
+			cancel()
+		}
+		for range reader.rows {
+		}
+	}()
+
+	idle := time.NewTimer(w.flush)
+	defer idle.Stop()
+	var flush <-chan time.Time
+
+	var batch []map[string]interface{}
+	var batchVersion uint64
+	var batchHasVersion bool
+	// lastVersion - версия, последней переданная fn в этом запросе
+	var lastVersion uint64
+	var delivered bool
+
+	deliver := func() error {
+		defer func() { batch, flush = nil, nil }()
+		if len(batch) == 0 {
+			return nil
+		}
+		if batchHasVersion {
+			continued := delivered && batchVersion == lastVersion
+			if w.hasVersion && batchVersion <= w.version && !continued {
+				return nil
+			}
+			w.version, w.hasVersion = batchVersion, true
+			lastVersion, delivered = batchVersion, true
+		}
+		return w.fn(batch)
+	}
+
+	for {
+		select {
+		case row, ok := <-reader.rows:
+			if !ok {
+				if reader.err != nil {
+					return n, nil, reader.err
+				}
+				return n, deliver(), nil
+			}
+			n++
+
+			version, ok := watchVersion(row[watchVersionColumn])
+			if len(batch) > 0 && (ok != batchHasVersion || version != batchVersion) {
+				if err := deliver(); err != nil {
+					return n, err, nil
+				}
+			}
+			batch = append(batch, row)
+			batchVersion, batchHasVersion = version, ok
+
+			if !idle.Stop() {
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			idle.Reset(w.flush)
+			flush = idle.C
+		case <-flush:
+			if err := deliver(); err != nil {
+				return n, err, nil
+			}
+		}
+	}
+}
+
+// kill отправляет KILL QUERY для запроса WATCH с идентификатором queryID
+func (w *watcher) kill(ctx context.Context, queryID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), watchKillTimeout)
+	defer cancel()
+
+	if _, err := w.db.Exec(ctx, "KILL QUERY WHERE query_id = ? ASYNC", queryID); err != nil {
+		w.db.warnf("Failed to kill watch query %s: %v", queryID, err)
+	}
+}
+
+// watchVersion возвращает значение колонки _version
+func watchVersion(value interface{}) (uint64, bool) {
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return val.Uint(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(val.Int()), true
+	default:
+		return 0, false
+	}
+}
+
+// newWatchQueryID генерирует идентификатор запроса WATCH
+func newWatchQueryID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate query id: %w", err)
+	}
+	return "chorm-watch-" + hex.EncodeToString(buf), nil
+}